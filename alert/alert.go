@@ -0,0 +1,318 @@
+// Package alert implements threshold alerting rules evaluated against collected metrics
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/takashabe/go-metrics/collect"
+)
+
+// Error variables
+var (
+	ErrInvalidCollector     = errors.New("invalid collector source")
+	ErrAlreadyExistRule     = errors.New("already exist rule")
+	ErrNotFoundMetricsValue = errors.New("not found metrics value")
+)
+
+// State is a state of Alert
+type State int
+
+// Enum of State
+const (
+	// StateInactive is not satisfied the condition
+	StateInactive State = iota
+	// StatePending is satisfied the condition shorter than the duration of Rule
+	StatePending
+	// StateFiring is satisfied the condition for the duration of Rule
+	StateFiring
+	// StateResolved is not satisfied the condition after firing
+	StateResolved
+)
+
+func (s State) String() string {
+	switch s {
+	case StateInactive:
+		return "inactive"
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	case StateResolved:
+		return "resolved"
+	default:
+		return "unknown"
+	}
+}
+
+// Alert is a state of Rule, notified when it is firing or resolved
+type Alert struct {
+	Rule  Rule
+	State State
+	// Value is the last evaluated value
+	Value float64
+	// ActiveAt is the time that the condition is satisfied
+	ActiveAt time.Time
+	// FiredAt is the time to fire
+	FiredAt time.Time
+	// ResolvedAt is the time to resolve
+	ResolvedAt time.Time
+}
+
+// MarshalJSON return specific encoded json, zero times are omitted
+func (a *Alert) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"name":`)
+	name, err := json.Marshal(a.Rule.name())
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(name)
+	buf.WriteString(`,"state":"`)
+	buf.WriteString(a.State.String())
+	buf.WriteString(`","value":`)
+	if math.IsNaN(a.Value) || math.IsInf(a.Value, 0) {
+		buf.WriteString(strconv.Quote(strconv.FormatFloat(a.Value, 'g', -1, 64)))
+	} else {
+		buf.WriteString(strconv.FormatFloat(a.Value, 'g', -1, 64))
+	}
+	for _, t := range []struct {
+		name string
+		v    time.Time
+	}{
+		{"active_at", a.ActiveAt},
+		{"fired_at", a.FiredAt},
+		{"resolved_at", a.ResolvedAt},
+	} {
+		if t.v.IsZero() {
+			continue
+		}
+		buf.WriteString(`,"` + t.name + `":"`)
+		buf.WriteString(t.v.Format(time.RFC3339Nano))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// ruleState is an evaluated state of Rule
+type ruleState struct {
+	alert Alert
+	// last is the previous value to calculate the rate
+	last   float64
+	lastAt time.Time
+}
+
+// Engine evaluates rules against the collector every interval
+type Engine struct {
+	Source   collect.Collector
+	Interval time.Duration
+	// Clock is a source of the time, default is collect.SystemClock
+	Clock collect.Clock
+
+	rules     []*ruleState
+	notifiers []Notifier
+	mu        sync.Mutex
+}
+
+// NewEngine return new Engine
+func NewEngine(c collect.Collector) (*Engine, error) {
+	if c == nil {
+		return nil, ErrInvalidCollector
+	}
+	return &Engine{
+		Source:   c,
+		Interval: time.Second,
+		Clock:    collect.SystemClock,
+	}, nil
+}
+
+// AddRule register rule, the name must be unique
+func (e *Engine) AddRule(r Rule) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rs := range e.rules {
+		if rs.alert.Rule.name() == r.name() {
+			return ErrAlreadyExistRule
+		}
+	}
+	e.rules = append(e.rules, &ruleState{alert: Alert{Rule: r}})
+	return nil
+}
+
+// AddRules register rules parsed by ParseRule
+func (e *Engine) AddRules(exprs ...string) error {
+	for _, expr := range exprs {
+		r, err := ParseRule(expr)
+		if err != nil {
+			return err
+		}
+		if err := e.AddRule(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddNotifier register notifier called when alerts are firing or resolved
+func (e *Engine) AddNotifier(n Notifier) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notifiers = append(e.notifiers, n)
+}
+
+// Alerts return current alerts of all rules in order of registered
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make([]Alert, 0, len(e.rules))
+	for _, rs := range e.rules {
+		res = append(res, rs.alert)
+	}
+	return res
+}
+
+// Evaluate evaluate all rules and notify changed alerts.
+// return the first error of notifiers
+func (e *Engine) Evaluate() error {
+	return e.evaluate(e.clock().Now())
+}
+
+// clock return Clock, or collect.SystemClock when it is nil
+func (e *Engine) clock() collect.Clock {
+	if e.Clock == nil {
+		return collect.SystemClock
+	}
+	return e.Clock
+}
+
+func (e *Engine) evaluate(now time.Time) error {
+	e.mu.Lock()
+	changed := make([]Alert, 0)
+	for _, rs := range e.rules {
+		if rs.update(e.Source, now) {
+			changed = append(changed, rs.alert)
+		}
+	}
+	notifiers := e.notifiers
+	e.mu.Unlock()
+
+	var res error
+	for _, a := range changed {
+		for _, n := range notifiers {
+			if err := n.Notify(a); err != nil && res == nil {
+				res = err
+			}
+		}
+	}
+	return res
+}
+
+// Run run Evaluate() goroutine every interval
+func (e *Engine) Run(ctx context.Context) {
+	go func() {
+		t := e.clock().NewTicker(e.Interval)
+		for {
+			select {
+			case now := <-t.Chan():
+				e.evaluate(now)
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// update evaluate the rule and change the state,
+// return true when the alert become firing or resolved
+func (rs *ruleState) update(c collect.Collector, now time.Time) bool {
+	a := &rs.alert
+	v, err := rs.value(c, now)
+	active := err == nil && a.Rule.Op.compare(v, a.Rule.Threshold)
+	if err == nil {
+		a.Value = v
+	}
+
+	if !active {
+		switch a.State {
+		case StatePending:
+			a.State = StateInactive
+			a.ActiveAt = time.Time{}
+		case StateFiring:
+			a.State = StateResolved
+			a.ResolvedAt = now
+			return true
+		}
+		return false
+	}
+
+	switch a.State {
+	case StateInactive, StateResolved:
+		a.State = StatePending
+		a.ActiveAt = now
+		a.FiredAt = time.Time{}
+		a.ResolvedAt = time.Time{}
+	case StateFiring:
+		return false
+	}
+	if now.Sub(a.ActiveAt) >= a.Rule.For {
+		a.State = StateFiring
+		a.FiredAt = now
+		return true
+	}
+	return false
+}
+
+// value return the evaluated value of the rule
+func (rs *ruleState) value(c collect.Collector, now time.Time) (float64, error) {
+	r := rs.alert.Rule
+	b, err := c.GetMetrics(r.Key)
+	if err != nil {
+		return 0, err
+	}
+	v, err := decodeValue(b, r.field())
+	if err != nil {
+		return 0, err
+	}
+	if r.Stat != StatRate {
+		return v, nil
+	}
+
+	last, lastAt := rs.last, rs.lastAt
+	rs.last, rs.lastAt = v, now
+	elapsed := now.Sub(lastAt).Seconds()
+	if lastAt.IsZero() || elapsed <= 0 {
+		return 0, ErrNotFoundMetricsValue
+	}
+	// counter is reset
+	if v < last {
+		last = 0
+	}
+	return (v - last) / elapsed, nil
+}
+
+// decodeValue return a number of the field in encoded metrics,
+// "NaN", "+Inf" and "-Inf" are encoded as string
+func decodeValue(b []byte, field string) (float64, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return 0, err
+	}
+	switch v := m[field].(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, errors.Wrapf(ErrNotFoundMetricsValue, "%s", field)
+}
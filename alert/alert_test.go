@@ -0,0 +1,182 @@
+package alert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takashabe/go-metrics/collect"
+)
+
+func TestNewEngine(t *testing.T) {
+	if _, err := NewEngine(nil); err != ErrInvalidCollector {
+		t.Errorf("want error %v, got %v", ErrInvalidCollector, err)
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	e, err := NewEngine(collect.NewSimpleCollector())
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := e.AddRules("counter a > 1", "counter b > 1"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := e.AddRules("counter a > 1"); err != ErrAlreadyExistRule {
+		t.Errorf("want error %v, got %v", ErrAlreadyExistRule, err)
+	}
+	if err := e.AddRule(Rule{Type: collect.TypeSet, Key: "s"}); err == nil {
+		t.Errorf("want error, got nil")
+	}
+	if got := len(e.Alerts()); got != 2 {
+		t.Errorf("want 2 alerts, got %d", got)
+	}
+}
+
+func TestEvaluateHistogram(t *testing.T) {
+	c := collect.NewSimpleCollector()
+	e, err := NewEngine(c)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := e.AddRules("histogram api.latency max > 250ms for 2m"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	var notified []Alert
+	e.AddNotifier(NotifierFunc(func(a Alert) error {
+		notified = append(notified, a)
+		return nil
+	}))
+
+	base := time.Unix(1500000000, 0)
+	cases := []struct {
+		value    float64
+		elapsed  time.Duration
+		state    State
+		notified int
+	}{
+		// not found metrics
+		{-1, 0, StateInactive, 0},
+		{100, time.Minute, StateInactive, 0},
+		{300, 2 * time.Minute, StatePending, 0},
+		{300, 3 * time.Minute, StatePending, 0},
+		{300, 4 * time.Minute, StateFiring, 1},
+		{300, 5 * time.Minute, StateFiring, 1},
+		{-1, 6 * time.Minute, StateResolved, 2},
+		{300, 7 * time.Minute, StatePending, 2},
+	}
+	for i, tc := range cases {
+		if tc.value >= 0 {
+			// reset to keep the value as max
+			c.Reset("api.latency")
+			c.Histogram("api.latency", tc.value)
+		}
+		if i == 6 {
+			// resolved by removed metrics
+			c.Delete("api.latency")
+		}
+		if err := e.evaluate(base.Add(tc.elapsed)); err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		a := e.Alerts()[0]
+		if a.State != tc.state {
+			t.Errorf("#%d: want state %s, got %s", i, tc.state, a.State)
+		}
+		if len(notified) != tc.notified {
+			t.Errorf("#%d: want %d notified, got %d", i, tc.notified, len(notified))
+		}
+	}
+
+	if a := notified[0]; a.State != StateFiring || a.Value != 300 ||
+		!a.ActiveAt.Equal(base.Add(2*time.Minute)) || !a.FiredAt.Equal(base.Add(4*time.Minute)) {
+		t.Errorf("want firing alert, got %+v", a)
+	}
+	if a := notified[1]; a.State != StateResolved || !a.ResolvedAt.Equal(base.Add(6*time.Minute)) {
+		t.Errorf("want resolved alert, got %+v", a)
+	}
+}
+
+func TestEvaluateCounterRate(t *testing.T) {
+	c := collect.NewSimpleCollector()
+	e, err := NewEngine(c)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := e.AddRules("counter errors rate > 5/s"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	base := time.Unix(1500000000, 0)
+	cases := []struct {
+		add   float64
+		state State
+		value float64
+	}{
+		// no previous value
+		{10, StateInactive, 0},
+		{50, StateInactive, 5},
+		{60, StateFiring, 6},
+		{0, StateResolved, 0},
+	}
+	for i, tc := range cases {
+		c.Add("errors", tc.add)
+		e.evaluate(base.Add(time.Duration(i) * 10 * time.Second))
+		a := e.Alerts()[0]
+		if a.State != tc.state {
+			t.Errorf("#%d: want state %s, got %s", i, tc.state, a.State)
+		}
+		if a.Value != tc.value {
+			t.Errorf("#%d: want value %v, got %v", i, tc.value, a.Value)
+		}
+	}
+}
+
+func TestAlertMarshalJSON(t *testing.T) {
+	a := Alert{
+		Rule:     Rule{Name: "high latency"},
+		State:    StateFiring,
+		Value:    300,
+		ActiveAt: time.Unix(1500000000, 0).UTC(),
+		FiredAt:  time.Unix(1500000120, 0).UTC(),
+	}
+	expect := `{"name":"high latency","state":"firing","value":300,"active_at":"2017-07-14T02:40:00Z","fired_at":"2017-07-14T02:42:00Z"}`
+	got, err := a.MarshalJSON()
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if string(got) != expect {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := collect.NewSimpleCollector()
+	e, err := NewEngine(c)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	clock := collect.NewFakeClock(time.Unix(1500000000, 0))
+	e.Clock = clock
+	if err := e.AddRules("gauge queue.size > 10"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	ch := make(chan Alert, 1)
+	e.AddNotifier(NewChannelNotifier(ch))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.Run(ctx)
+	clock.WaitTickers(1)
+	c.Gauge("queue.size", 20)
+	clock.Advance(e.Interval)
+	if a := <-ch; a.State != StateFiring || !a.FiredAt.Equal(clock.Now()) {
+		t.Errorf("want firing at %v, got %+v", clock.Now(), a)
+	}
+	c.Gauge("queue.size", 0)
+	clock.Advance(e.Interval)
+	if a := <-ch; a.State != StateResolved {
+		t.Errorf("want resolved, got %+v", a)
+	}
+
+	cancel()
+	clock.WaitTickers(0)
+}
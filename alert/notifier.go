@@ -0,0 +1,100 @@
+package alert
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// about notifier errors
+var (
+	ErrDroppedAlert    = errors.New("dropped alert")
+	ErrWebhookResponse = errors.New("unexpected webhook response")
+)
+
+// Notifier is called when alerts are firing or resolved
+type Notifier interface {
+	Notify(Alert) error
+}
+
+// NotifierFunc is an adapter to use ordinary functions as Notifier
+type NotifierFunc func(Alert) error
+
+// Notify call f(a)
+func (f NotifierFunc) Notify(a Alert) error {
+	return f(a)
+}
+
+// LogNotifier is implemented Notifier, output alerts to the logger
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+// NewLogNotifier return new LogNotifier, use the standard logger when l is nil
+func NewLogNotifier(l *log.Logger) *LogNotifier {
+	return &LogNotifier{Logger: l}
+}
+
+// Notify output the alert
+func (n *LogNotifier) Notify(a Alert) error {
+	format, args := "[%s] %s value=%v", []interface{}{a.State, a.Rule.name(), a.Value}
+	if n.Logger == nil {
+		log.Printf(format, args...)
+		return nil
+	}
+	n.Logger.Printf(format, args...)
+	return nil
+}
+
+// WebhookNotifier is implemented Notifier, post encoded alerts to the URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier return new WebhookNotifier using http.DefaultClient
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: http.DefaultClient,
+	}
+}
+
+// Notify post the alert as json, return ErrWebhookResponse when the status is not 2xx
+func (n *WebhookNotifier) Notify(a Alert) error {
+	b, err := a.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	res, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Wrapf(ErrWebhookResponse, "status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// ChannelNotifier is implemented Notifier, send alerts to the channel
+type ChannelNotifier struct {
+	C chan<- Alert
+}
+
+// NewChannelNotifier return new ChannelNotifier
+func NewChannelNotifier(c chan<- Alert) *ChannelNotifier {
+	return &ChannelNotifier{C: c}
+}
+
+// Notify send the alert without blocking, return ErrDroppedAlert when the channel is full
+func (n *ChannelNotifier) Notify(a Alert) error {
+	select {
+	case n.C <- a:
+		return nil
+	default:
+		return ErrDroppedAlert
+	}
+}
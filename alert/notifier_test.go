@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func testAlert() Alert {
+	return Alert{Rule: Rule{Name: "test"}, State: StateFiring, Value: 1}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	n := NewLogNotifier(log.New(&buf, "", 0))
+	if err := n.Notify(testAlert()); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := "[firing] test value=1\n"
+	if got := buf.String(); got != expect {
+		t.Errorf("want %q, got %q", expect, got)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	cases := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusOK, nil},
+		{http.StatusInternalServerError, ErrWebhookResponse},
+	}
+	for i, tc := range cases {
+		var body []byte
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(tc.status)
+		}))
+
+		err := NewWebhookNotifier(ts.URL).Notify(testAlert())
+		ts.Close()
+		if errors.Cause(err) != tc.err {
+			t.Fatalf("#%d: want error %v, got %v", i, tc.err, err)
+		}
+		expect := `{"name":"test","state":"firing","value":1}`
+		if string(body) != expect {
+			t.Errorf("#%d: want %s, got %s", i, expect, body)
+		}
+	}
+}
+
+func TestChannelNotifier(t *testing.T) {
+	ch := make(chan Alert, 1)
+	n := NewChannelNotifier(ch)
+	if err := n.Notify(testAlert()); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := n.Notify(testAlert()); err != ErrDroppedAlert {
+		t.Errorf("want error %v, got %v", ErrDroppedAlert, err)
+	}
+	if a := <-ch; a.Rule.Name != "test" {
+		t.Errorf("want alert test, got %v", a)
+	}
+}
@@ -0,0 +1,257 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/takashabe/go-metrics/collect"
+)
+
+// about rule errors
+var (
+	ErrInvalidRule = errors.New("invalid rule")
+)
+
+// Op is a comparison operator of Rule
+type Op int
+
+// Enum of Op
+const (
+	OpGreater Op = iota
+	OpGreaterOrEqual
+	OpLess
+	OpLessOrEqual
+	OpEqual
+	OpNotEqual
+)
+
+var opNames = map[Op]string{
+	OpGreater:        ">",
+	OpGreaterOrEqual: ">=",
+	OpLess:           "<",
+	OpLessOrEqual:    "<=",
+	OpEqual:          "==",
+	OpNotEqual:       "!=",
+}
+
+func (o Op) String() string {
+	if s, ok := opNames[o]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// compare return whether v satisfy the operator with threshold
+func (o Op) compare(v, threshold float64) bool {
+	switch o {
+	case OpGreater:
+		return v > threshold
+	case OpGreaterOrEqual:
+		return v >= threshold
+	case OpLess:
+		return v < threshold
+	case OpLessOrEqual:
+		return v <= threshold
+	case OpEqual:
+		return v == threshold
+	case OpNotEqual:
+		return v != threshold
+	}
+	return false
+}
+
+// parseOp return Op of the string
+func parseOp(s string) (Op, bool) {
+	for o, name := range opNames {
+		if name == s {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// Stat is a evaluated value of the metrics
+type Stat string
+
+// Enum of Stat
+const (
+	// StatValue is a value of counter and gauge
+	StatValue Stat = "value"
+	// StatRate is a increase per second of counter
+	StatRate Stat = "rate"
+	// StatCount, StatAvg, StatMax, StatMedian and StatP95 are summaries of histogram
+	StatCount  Stat = "count"
+	StatAvg    Stat = "avg"
+	StatMax    Stat = "max"
+	StatMedian Stat = "median"
+	StatP95    Stat = "p95"
+)
+
+// statFields is suffixes of the aggregated key by stats of histogram
+var statFields = map[Stat]string{
+	StatCount:  ".count",
+	StatAvg:    ".avg",
+	StatMax:    ".max",
+	StatMedian: ".median",
+	StatP95:    ".95percentile",
+}
+
+// Rule is a threshold of the metrics, fires when the condition continues for the duration
+type Rule struct {
+	// Name identifies the rule, the expression is used by ParseRule
+	Name string
+	// Type is one of TypeCounter, TypeGauge and TypeHistogram
+	Type      collect.MetricType
+	Key       string
+	Stat      Stat
+	Op        Op
+	Threshold float64
+	For       time.Duration
+}
+
+// ParseRule return Rule from the expression, e.g. "histogram api.latency p95 > 250ms for 2m".
+// the format is "<type> <key> [<stat>] <op> <threshold> [for <duration>]",
+// a threshold of the duration is converted to milliseconds, and "/s", "/m" or "/h" is a rate per unit
+func ParseRule(expr string) (Rule, error) {
+	fields := strings.Fields(expr)
+	invalid := func(msg string) (Rule, error) {
+		return Rule{}, errors.Wrapf(ErrInvalidRule, "%s: %q", msg, expr)
+	}
+	if len(fields) < 4 {
+		return invalid("too few fields")
+	}
+
+	r := Rule{Name: expr, Key: fields[1], Stat: StatValue}
+	switch fields[0] {
+	case collect.TypeCounter.String():
+		r.Type = collect.TypeCounter
+	case collect.TypeGauge.String():
+		r.Type = collect.TypeGauge
+	case collect.TypeHistogram.String():
+		r.Type = collect.TypeHistogram
+	default:
+		return invalid("unsupported type " + fields[0])
+	}
+
+	rest := fields[2:]
+	if _, ok := parseOp(rest[0]); !ok {
+		r.Stat = Stat(rest[0])
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return invalid("missing threshold")
+	}
+	op, ok := parseOp(rest[0])
+	if !ok {
+		return invalid("unknown operator " + rest[0])
+	}
+	r.Op = op
+	threshold, err := parseThreshold(rest[1])
+	if err != nil {
+		return invalid(err.Error())
+	}
+	r.Threshold = threshold
+
+	rest = rest[2:]
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 2 && rest[0] == "for":
+		d, err := time.ParseDuration(rest[1])
+		if err != nil {
+			return invalid(err.Error())
+		}
+		r.For = d
+	default:
+		return invalid("unexpected " + strings.Join(rest, " "))
+	}
+
+	if err := r.validate(); err != nil {
+		return Rule{}, errors.Wrapf(err, "%q", expr)
+	}
+	return r, nil
+}
+
+// rateUnits is suffixes of the rate threshold
+var rateUnits = map[string]time.Duration{
+	"/s": time.Second,
+	"/m": time.Minute,
+	"/h": time.Hour,
+}
+
+// parseThreshold return a number, a duration in milliseconds or a rate per second
+func parseThreshold(s string) (float64, error) {
+	for suffix, unit := range rateUnits {
+		if strings.HasSuffix(s, suffix) {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				return 0, err
+			}
+			return v / unit.Seconds(), nil
+		}
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold %s", s)
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// validate return ErrInvalidRule when the stat is not supported by the type
+func (r Rule) validate() error {
+	if r.Key == "" {
+		return errors.Wrap(ErrInvalidRule, "empty key")
+	}
+	if _, ok := opNames[r.Op]; !ok {
+		return errors.Wrap(ErrInvalidRule, "unknown operator")
+	}
+	if r.For < 0 {
+		return errors.Wrap(ErrInvalidRule, "negative duration")
+	}
+
+	var ok bool
+	switch r.Type {
+	case collect.TypeCounter:
+		ok = r.Stat == StatValue || r.Stat == StatRate
+	case collect.TypeGauge:
+		ok = r.Stat == StatValue
+	case collect.TypeHistogram:
+		_, ok = statFields[r.Stat]
+	default:
+		return errors.Wrapf(ErrInvalidRule, "unsupported type %s", r.Type)
+	}
+	if !ok {
+		return errors.Wrapf(ErrInvalidRule, "unsupported stat %s of %s", r.Stat, r.Type)
+	}
+	return nil
+}
+
+// field return the aggregated key of the metrics
+func (r Rule) field() string {
+	if r.Type == collect.TypeHistogram {
+		return r.Key + statFields[r.Stat]
+	}
+	return r.Key
+}
+
+// String return the expression of the rule
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s %s %s %s %s", r.Type, r.Key, r.Stat, r.Op, strconv.FormatFloat(r.Threshold, 'g', -1, 64))
+	if r.For > 0 {
+		s += " for " + r.For.String()
+	}
+	return s
+}
+
+// name return Name, or the expression when empty
+func (r Rule) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.String()
+}
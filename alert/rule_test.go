@@ -0,0 +1,83 @@
+package alert
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/takashabe/go-metrics/collect"
+)
+
+func TestParseRule(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect Rule
+		err    error
+	}{
+		{
+			"histogram api.latency p95 > 250ms for 2m",
+			Rule{Type: collect.TypeHistogram, Key: "api.latency", Stat: StatP95, Op: OpGreater, Threshold: 250, For: 2 * time.Minute},
+			nil,
+		},
+		{
+			"counter errors rate > 5/s",
+			Rule{Type: collect.TypeCounter, Key: "errors", Stat: StatRate, Op: OpGreater, Threshold: 5},
+			nil,
+		},
+		{
+			"counter errors rate >= 60/m",
+			Rule{Type: collect.TypeCounter, Key: "errors", Stat: StatRate, Op: OpGreaterOrEqual, Threshold: 1},
+			nil,
+		},
+		{
+			"gauge queue.size < 1.5",
+			Rule{Type: collect.TypeGauge, Key: "queue.size", Stat: StatValue, Op: OpLess, Threshold: 1.5},
+			nil,
+		},
+		{
+			"histogram db avg <= 1.5s for 30s",
+			Rule{Type: collect.TypeHistogram, Key: "db", Stat: StatAvg, Op: OpLessOrEqual, Threshold: 1500, For: 30 * time.Second},
+			nil,
+		},
+		{"set users > 1", Rule{}, ErrInvalidRule},
+		{"gauge queue.size rate > 1", Rule{}, ErrInvalidRule},
+		{"histogram api.latency > 1", Rule{}, ErrInvalidRule},
+		{"counter errors ~ 1", Rule{}, ErrInvalidRule},
+		{"counter errors > x", Rule{}, ErrInvalidRule},
+		{"counter errors > 1 for", Rule{}, ErrInvalidRule},
+		{"counter errors > 1 for x", Rule{}, ErrInvalidRule},
+		{"counter errors", Rule{}, ErrInvalidRule},
+	}
+	for i, tc := range cases {
+		got, err := ParseRule(tc.input)
+		if errors.Cause(err) != tc.err {
+			t.Fatalf("#%d: want error %v, got %v", i, tc.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		tc.expect.Name = tc.input
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %+v, got %+v", i, tc.expect, got)
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	r := Rule{Type: collect.TypeHistogram, Key: "api.latency", Stat: StatP95, Op: OpGreater, Threshold: 250, For: 2 * time.Minute}
+	expect := "histogram api.latency p95 > 250 for 2m0s"
+	if got := r.String(); got != expect {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+
+	// parse own expression
+	parsed, err := ParseRule(r.String())
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	parsed.Name = ""
+	if !reflect.DeepEqual(parsed, r) {
+		t.Errorf("want %+v, got %+v", r, parsed)
+	}
+}
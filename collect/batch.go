@@ -0,0 +1,124 @@
+package collect
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// BatchError is errors of operations not applied by Batch.Commit,
+// each error is wrapped with the key, and errors.Cause return ErrInvalidKey or ErrMismatchMetricType
+type BatchError []error
+
+// Error return joined messages
+func (e BatchError) Error() string {
+	var buf bytes.Buffer
+	for i, err := range e {
+		if i != 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// batchOp is a queued operation of Batch
+type batchOp struct {
+	typ   MetricType
+	key   string
+	value float64
+	str   string
+}
+
+// Batch queues Add, Gauge, Histogram and Set operations,
+// and applies them under a single lock of the collector by Commit
+type Batch struct {
+	c   *SimpleCollector
+	ops []batchOp
+}
+
+// NewBatch return new Batch applied to the collector
+func (c *SimpleCollector) NewBatch() *Batch {
+	return &Batch{c: c}
+}
+
+// Add queue count for CounterMetrics
+func (b *Batch) Add(key string, delta float64) *Batch {
+	b.ops = append(b.ops, batchOp{typ: TypeCounter, key: key, value: delta})
+	return b
+}
+
+// Gauge queue metrics for GaugeMetrics
+func (b *Batch) Gauge(key string, delta float64) *Batch {
+	b.ops = append(b.ops, batchOp{typ: TypeGauge, key: key, value: delta})
+	return b
+}
+
+// Histogram queue metrics for Histogram
+func (b *Batch) Histogram(key string, delta float64) *Batch {
+	b.ops = append(b.ops, batchOp{typ: TypeHistogram, key: key, value: delta})
+	return b
+}
+
+// Set queue metrics for Set
+func (b *Batch) Set(key string, delta string) *Batch {
+	b.ops = append(b.ops, batchOp{typ: TypeSet, key: key, str: delta})
+	return b
+}
+
+// Len return number of queued operations
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Commit apply queued operations in order under a single lock, and clear the queue to reuse Batch.
+// operations conflicted with other metric types are skipped and returned as BatchError
+func (b *Batch) Commit() error {
+	c := b.c
+	c.observerMu.RLock()
+	observed := len(c.observers) > 0
+	c.observerMu.RUnlock()
+
+	var (
+		errs   BatchError
+		events []Event
+	)
+	c.mu.Lock()
+	for _, op := range b.ops {
+		var (
+			key string
+			err error
+		)
+		switch op.typ {
+		case TypeCounter:
+			key, err = c.add(op.key, op.value, nil)
+		case TypeGauge:
+			key, err = c.gauge(op.key, op.value)
+		case TypeHistogram:
+			key, err = c.histogram(op.key, op.value, nil)
+		case TypeSet:
+			key, err = c.set(op.key, op.str)
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to apply %s %q", op.typ, op.key))
+			continue
+		}
+		if observed {
+			e := Event{Key: key, Type: op.typ, Value: op.value}
+			if op.typ == TypeSet {
+				e = Event{Key: key, Type: op.typ, Values: []string{op.str}}
+			}
+			events = append(events, e)
+		}
+	}
+	c.mu.Unlock()
+	b.ops = b.ops[:0]
+
+	for _, e := range events {
+		c.notify(e)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
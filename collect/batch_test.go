@@ -0,0 +1,173 @@
+package collect
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBatch(t *testing.T) {
+	c := NewSimpleCollector()
+	var events []Event
+	c.AddObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	b := c.NewBatch().
+		Add("c", 1).
+		Add("c", 2).
+		Gauge("g", 3).
+		Histogram("h", 1).
+		Histogram("h", 3).
+		Set("s", "a").
+		Set("s", "b")
+	if got := b.Len(); got != 7 {
+		t.Fatalf("want len 7, got %d", got)
+	}
+	if _, err := c.GetMetrics("c"); err != ErrNotFoundMetrics {
+		t.Fatalf("want error %v before commit, got %v", ErrNotFoundMetrics, err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got := b.Len(); got != 0 {
+		t.Fatalf("want len 0 after commit, got %d", got)
+	}
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"c", []byte(`{"c":3}`)},
+		{"g", []byte(`{"g":3}`)},
+		{"h", []byte(`{"h.95percentile":2.9,"h.avg":2,"h.count":2,"h.max":3,"h.median":3}`)},
+		{"s", []byte(`{"s":["a","b"]}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+	if len(events) != 7 {
+		t.Fatalf("want 7 events, got %d", len(events))
+	}
+	if e := events[5]; e.Type != TypeSet || !reflect.DeepEqual(e.Values, []string{"a"}) {
+		t.Errorf("want set event, got %v", e)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Add("c", 1)
+	c.SetKeyPolicy(PrometheusKeyPolicy(KeyModeReject))
+
+	err := c.NewBatch().
+		Gauge("c", 1).
+		Add("c", 1).
+		Add("bad key", 1).
+		Commit()
+	errs, ok := err.(BatchError)
+	if !ok {
+		t.Fatalf("want BatchError, got %v", err)
+	}
+	expect := []error{ErrMismatchMetricType, ErrInvalidKey}
+	if len(errs) != len(expect) {
+		t.Fatalf("want %d errors, got %v", len(expect), errs)
+	}
+	for i, e := range expect {
+		if got := errors.Cause(errs[i]); got != e {
+			t.Errorf("#%d: want %v, got %v", i, e, got)
+		}
+	}
+
+	// applied other operations
+	got, err := c.GetMetrics("c")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"c":2}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+var benchmarkKeys = func() []string {
+	keys := make([]string, 8)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+func BenchmarkRecordIndividual(b *testing.B) {
+	c := NewSimpleCollector()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add(benchmarkKeys[0], 1)
+		c.Add(benchmarkKeys[1], 1)
+		c.Gauge(benchmarkKeys[2], 1)
+		c.Gauge(benchmarkKeys[3], 1)
+		c.Histogram(benchmarkKeys[4], 1)
+		c.Histogram(benchmarkKeys[5], 1)
+		c.Set(benchmarkKeys[6], "a")
+		c.Set(benchmarkKeys[7], "b")
+	}
+}
+
+func BenchmarkRecordBatch(b *testing.B) {
+	c := NewSimpleCollector()
+	batch := c.NewBatch()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.Add(benchmarkKeys[0], 1).
+			Add(benchmarkKeys[1], 1).
+			Gauge(benchmarkKeys[2], 1).
+			Gauge(benchmarkKeys[3], 1).
+			Histogram(benchmarkKeys[4], 1).
+			Histogram(benchmarkKeys[5], 1).
+			Set(benchmarkKeys[6], "a").
+			Set(benchmarkKeys[7], "b").
+			Commit()
+	}
+}
+
+func BenchmarkRecordIndividualParallel(b *testing.B) {
+	c := NewSimpleCollector()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add(benchmarkKeys[0], 1)
+			c.Add(benchmarkKeys[1], 1)
+			c.Gauge(benchmarkKeys[2], 1)
+			c.Gauge(benchmarkKeys[3], 1)
+			c.Histogram(benchmarkKeys[4], 1)
+			c.Histogram(benchmarkKeys[5], 1)
+			c.Set(benchmarkKeys[6], "a")
+			c.Set(benchmarkKeys[7], "b")
+		}
+	})
+}
+
+func BenchmarkRecordBatchParallel(b *testing.B) {
+	c := NewSimpleCollector()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		batch := c.NewBatch()
+		for pb.Next() {
+			batch.Add(benchmarkKeys[0], 1).
+				Add(benchmarkKeys[1], 1).
+				Gauge(benchmarkKeys[2], 1).
+				Gauge(benchmarkKeys[3], 1).
+				Histogram(benchmarkKeys[4], 1).
+				Histogram(benchmarkKeys[5], 1).
+				Set(benchmarkKeys[6], "a").
+				Set(benchmarkKeys[7], "b").
+				Commit()
+		}
+	})
+}
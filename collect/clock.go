@@ -0,0 +1,136 @@
+package collect
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a source of the current time and tickers
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of Clock at intervals
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is Clock of the wall time
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return &systemTicker{t: time.NewTicker(d)}
+}
+
+// systemTicker is implemented Ticker by time.Ticker
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t *systemTicker) Chan() <-chan time.Time {
+	return t.t.C
+}
+
+func (t *systemTicker) Stop() {
+	t.t.Stop()
+}
+
+// FakeClock is implemented Clock, the time is changed only by Advance
+type FakeClock struct {
+	now     time.Time
+	tickers []*fakeTicker
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewFakeClock return new FakeClock started at now
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now return the current time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker return Ticker delivered ticks by Advance, d must be greater than zero
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   c.now.Add(d),
+		clock:  c,
+	}
+	c.tickers = append(c.tickers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance move the time forward by d, and deliver ticks of the elapsed time.
+// ticks are dropped when the receiver is not ready the same as time.Ticker
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// WaitTickers block until the number of active tickers is n,
+// e.g. wait for a goroutine to start or stop its ticker
+func (c *FakeClock) WaitTickers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.tickers) != n {
+		c.cond.Wait()
+	}
+}
+
+// fakeTicker is implemented Ticker of FakeClock
+type fakeTicker struct {
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+	clock  *FakeClock
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.tickers {
+		if v == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			c.cond.Broadcast()
+			return
+		}
+	}
+}
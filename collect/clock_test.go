@@ -0,0 +1,127 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	c := NewFakeClock(base)
+	ticker := c.NewTicker(time.Second)
+	c.WaitTickers(1)
+
+	c.Advance(500 * time.Millisecond)
+	if got, expect := c.Now(), base.Add(500*time.Millisecond); !got.Equal(expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	select {
+	case tick := <-ticker.Chan():
+		t.Fatalf("want no tick, got %v", tick)
+	default:
+	}
+
+	// deliver the first tick and drop others the same as time.Ticker
+	c.Advance(3 * time.Second)
+	if tick := <-ticker.Chan(); !tick.Equal(base.Add(time.Second)) {
+		t.Errorf("want tick %v, got %v", base.Add(time.Second), tick)
+	}
+	select {
+	case tick := <-ticker.Chan():
+		t.Fatalf("want no tick, got %v", tick)
+	default:
+	}
+	c.Advance(500 * time.Millisecond)
+	if tick := <-ticker.Chan(); !tick.Equal(base.Add(4 * time.Second)) {
+		t.Errorf("want tick %v, got %v", base.Add(4*time.Second), tick)
+	}
+
+	ticker.Stop()
+	c.WaitTickers(0)
+	c.Advance(time.Hour)
+	select {
+	case tick := <-ticker.Chan():
+		t.Fatalf("want no tick after stop, got %v", tick)
+	default:
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	clock := NewFakeClock(base)
+	c1 := NewSimpleCollector()
+	c1.SetClock(clock)
+	c2 := NewSimpleCollector()
+	c2.SetClock(clock)
+
+	c2.Gauge("g", 2)
+	c2.Snapshot("s", []string{"b"})
+	clock.Advance(time.Second)
+	c1.Gauge("g", 1)
+	c1.Snapshot("s", []string{"a"})
+	c1.AddWithExemplar("c", 1, Exemplar{TraceID: "abc"})
+
+	// keep later values
+	if err := c1.Merge(c2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"g", []byte(`{"g":1}`)},
+		{"s", []byte(`{"s":["a"]}`)},
+		{"c", []byte(`{"c":1,"c.exemplar":{"labels":{"trace_id":"abc"},"timestamp":1500000001,"value":1}}`)},
+	}
+	for i, tc := range cases {
+		got, err := c1.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+}
+
+func TestExpDecaySampleUsesClock(t *testing.T) {
+	base := time.Unix(1500000000, 0)
+	clock := NewFakeClock(base)
+	c := NewSimpleCollector()
+	c.SetClock(clock)
+	if err := c.RegisterHistogram("h", NewExpDecaySample(10, 0.1)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	s := c.metrics["h"].(*HistogramMetrics).value.(*ExpDecaySample)
+	if !s.landmark.Equal(base) {
+		t.Errorf("want landmark %v, got %v", base, s.landmark)
+	}
+
+	// old values are replaced by recent values of the clock
+	for i := 0; i < 100; i++ {
+		c.Histogram("h", 1)
+	}
+	clock.Advance(2 * rescaleThreshold)
+	for i := 0; i < 100; i++ {
+		c.Histogram("h", 2)
+	}
+	if got, expect := s.Values(), []float64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+
+	// merged and reset samples use the clock of the collector
+	dst := NewSimpleCollector()
+	dst.SetClock(clock)
+	if err := dst.Merge(c); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	merged := dst.metrics["h"].(*HistogramMetrics).value.(*ExpDecaySample)
+	clock.Advance(time.Minute)
+	for i, sample := range []*ExpDecaySample{s, merged} {
+		sample.Reset()
+		if !sample.landmark.Equal(clock.Now()) {
+			t.Errorf("#%d: want landmark %v, got %v", i, clock.Now(), sample.landmark)
+		}
+	}
+}
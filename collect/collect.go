@@ -4,17 +4,22 @@ package collect
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"math"
+	"math/rand"
 	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 // about metrics errors
 var (
-	ErrNotFoundMetrics = errors.New("not found metrics")
+	ErrNotFoundMetrics     = errors.New("not found metrics")
+	ErrAlreadyExistMetrics = errors.New("already exist metrics")
+	ErrMismatchMetricType  = errors.New("mismatch metric type")
+	ErrInvalidCollector    = errors.New("invalid collector")
 )
 
 // MetricType is metric types
@@ -28,6 +33,11 @@ const (
 	TypeHistogram
 	TypeSet
 	TypeSnapshot
+	TypeTopK
+	TypeTally
+	TypeInfo
+	TypeStateSet
+	TypeDerived
 )
 
 func (m MetricType) String() string {
@@ -42,6 +52,16 @@ func (m MetricType) String() string {
 		return "set"
 	case TypeSnapshot:
 		return "snapshot"
+	case TypeTopK:
+		return "topk"
+	case TypeTally:
+		return "tally"
+	case TypeInfo:
+		return "info"
+	case TypeStateSet:
+		return "stateset"
+	case TypeDerived:
+		return "derived"
 	default:
 		return "not supported metric type"
 	}
@@ -51,21 +71,28 @@ func (m MetricType) String() string {
 type Metrics interface {
 	Aggregate() map[string]Data
 	GetType() MetricType
+	Merge(Metrics) error
+	Reset()
 }
 
 // CounterMetrics is implemented Metirics for Counter
 type CounterMetrics struct {
-	key   string
-	value *Float
+	key      string
+	value    *Float
+	exemplar *Exemplar
 }
 
 // Aggregate return counter key and value
 func (m *CounterMetrics) Aggregate() map[string]Data {
 	m.value.mu.RLock()
 	defer m.value.mu.RUnlock()
-	return map[string]Data{
+	agg := map[string]Data{
 		m.key: m.value,
 	}
+	if m.exemplar != nil {
+		agg[m.key+".exemplar"] = m.exemplar
+	}
+	return agg
 }
 
 // GetType return MetricType
@@ -73,10 +100,21 @@ func (m *CounterMetrics) GetType() MetricType {
 	return TypeCounter
 }
 
+// add add delta to the counter, and replace the exemplar when e is not nil
+func (m *CounterMetrics) add(delta float64, e *Exemplar) {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.f += delta
+	if e != nil {
+		m.exemplar = e
+	}
+}
+
 // GaugeMetrics is implemented Metrics for Gauge
 type GaugeMetrics struct {
-	key   string
-	value *Float
+	key     string
+	value   *Float
+	updated time.Time
 }
 
 // Aggregate return gauge key and value
@@ -91,10 +129,24 @@ func (m *GaugeMetrics) GetType() MetricType {
 	return TypeGauge
 }
 
+func (m *GaugeMetrics) get() (float64, time.Time) {
+	m.value.mu.RLock()
+	defer m.value.mu.RUnlock()
+	return m.value.f, m.updated
+}
+
+func (m *GaugeMetrics) set(v float64, t time.Time) {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.f = v
+	m.updated = t
+}
+
 // HistogramMetrics is implemented Metirics for Histogram
 type HistogramMetrics struct {
-	key   string
-	value *FloatSlice
+	key       string
+	value     Sample
+	exemplars exemplarBuckets
 }
 
 // minPercentileSize is minimum number of size for percentile analysis
@@ -102,23 +154,43 @@ const minPercentileSize = 2
 
 // Aggregate returns aggregated histogram metrics
 func (m *HistogramMetrics) Aggregate() map[string]Data {
-	m.value.mu.Lock()
-	sort.Float64s(m.value.v)
-	m.value.mu.Unlock()
+	var list summaryList
+	if s, ok := m.value.(WeightedSample); ok {
+		list = newWeightedFloats(s.WeightedValues())
+	} else {
+		list = sortedFloats(m.value.Values())
+	}
+	median := list.median()
+	p95 := list.percentile(0.95)
 
-	return map[string]Data{
-		m.key + ".count":        m.count(),
-		m.key + ".avg":          m.average(),
-		m.key + ".max":          m.max(),
-		m.key + ".median":       m.median(),
-		m.key + ".95percentile": m.percentile(0.95),
+	agg := map[string]Data{
+		m.key + ".count":        &Float{f: float64(m.value.Count())},
+		m.key + ".avg":          &Float{f: list.average()},
+		m.key + ".max":          &Float{f: list.max()},
+		m.key + ".median":       &Float{f: median},
+		m.key + ".95percentile": &Float{f: p95},
 	}
+
+	// the most recent exemplar of each quantile range
+	for k, e := range m.exemplars.latestByRange(median, p95) {
+		agg[m.key+"."+k+".exemplar"] = e
+	}
+	return agg
 }
 
 // MarshalJSONWithOrder return keeped order json
 func (m *HistogramMetrics) MarshalJSONWithOrder() ([]byte, error) {
+	return marshalJSONWithOrder(m.Aggregate(), ShortestPrecision)
+}
+
+// precisionMarshaler is Data encoded with precision of float values
+type precisionMarshaler interface {
+	format(prec int) []byte
+}
+
+// marshalJSONWithOrder return json sorted by keys, float values are formatted with prec
+func marshalJSONWithOrder(agg map[string]Data, prec int) ([]byte, error) {
 	sortKeys := make([]string, 0)
-	agg := m.Aggregate()
 	for k := range agg {
 		sortKeys = append(sortKeys, k)
 	}
@@ -131,69 +203,67 @@ func (m *HistogramMetrics) MarshalJSONWithOrder() ([]byte, error) {
 		if k != 0 {
 			buf.Write([]byte(","))
 		}
-		value, err := agg[v].MarshalJSON()
+		var (
+			value []byte
+			err   error
+		)
+		if f, ok := agg[v].(precisionMarshaler); ok {
+			value = f.format(prec)
+		} else {
+			value, err = agg[v].MarshalJSON()
+		}
 		if err != nil {
 			return nil, err
 		}
-		buf.Write([]byte(fmt.Sprintf("%q:%s", v, value)))
+		buf.Write(quoteJSON(v))
+		buf.WriteByte(':')
+		buf.Write(value)
 	}
 	buf.Write([]byte("}"))
 	return buf.Bytes(), nil
 }
 
-func (m *HistogramMetrics) count() Data {
-	m.value.mu.RLock()
-	defer m.value.mu.RUnlock()
-	return &Float{
-		f: float64(len(m.value.v)),
-	}
+// summaryList is sorted values summarized by histogram
+type summaryList interface {
+	average() float64
+	max() float64
+	median() float64
+	percentile(n float64) float64
 }
 
-func (m *HistogramMetrics) average() Data {
-	m.value.mu.RLock()
-	defer m.value.mu.RUnlock()
+// sortedFloats is a sorted list of histogram values
+type sortedFloats []float64
+
+func (list sortedFloats) average() float64 {
+	if len(list) == 0 {
+		return 0
+	}
 	var total float64
-	for _, v := range m.value.v {
+	for _, v := range list {
 		total += v
 	}
-	return &Float{
-		f: total / float64(len(m.value.v)),
-	}
+	return total / float64(len(list))
 }
 
-// note: expect sorted list
-func (m *HistogramMetrics) max() Data {
-	m.value.mu.RLock()
-	defer m.value.mu.RUnlock()
+func (list sortedFloats) max() float64 {
 	var max float64
-	if size := len(m.value.v); size > 0 {
-		max = m.value.v[size-1]
-	}
-	return &Float{
-		f: max,
+	if size := len(list); size > 0 {
+		max = list[size-1]
 	}
+	return max
 }
 
-// note: expect sorted list
-func (m *HistogramMetrics) median() Data {
-	m.value.mu.RLock()
-	defer m.value.mu.RUnlock()
+func (list sortedFloats) median() float64 {
 	var median float64
-	if size := len(m.value.v); size > 0 {
-		median = m.value.v[size/2]
-	}
-	return &Float{
-		f: median,
+	if size := len(list); size > 0 {
+		median = list[size/2]
 	}
+	return median
 }
 
-// note: expect sorted list
-func (m *HistogramMetrics) percentile(n float64) Data {
-	m.value.mu.RLock()
-	defer m.value.mu.RUnlock()
-	list := m.value.v
+func (list sortedFloats) percentile(n float64) float64 {
 	if 1.0 <= n || len(list) < minPercentileSize {
-		return &Float{}
+		return 0
 	}
 
 	// use linear interpolation
@@ -202,11 +272,7 @@ func (m *HistogramMetrics) percentile(n float64) Data {
 	rFloor := int(math.Floor(r))
 	rCeil := int(math.Ceil(r))
 	// -1 means in accordance with slice index
-	q := list[rFloor-1] + (r-float64(rFloor))*(list[rCeil-1]-list[rFloor-1])
-
-	return &Float{
-		f: q,
-	}
+	return list[rFloor-1] + (r-float64(rFloor))*(list[rCeil-1]-list[rFloor-1])
 }
 
 // GetType return MetricType
@@ -244,8 +310,9 @@ func (m *SetMetrics) GetType() MetricType {
 
 // SnapshotMetrics is implemented Metrics for Snapshot
 type SnapshotMetrics struct {
-	key   string
-	value *Map
+	key     string
+	value   *Map
+	updated time.Time
 }
 
 // Aggregate return sorted sort key and value
@@ -281,17 +348,56 @@ type Float struct {
 	mu sync.RWMutex
 }
 
+// ShortestPrecision is a precision of the shortest representation that round-trips
+const ShortestPrecision = -1
+
 // MarshalJSON return specific encoded json
 func (f *Float) MarshalJSON() ([]byte, error) {
+	return f.format(ShortestPrecision), nil
+}
+
+// format return encoded json with prec digits after the decimal point.
+// ShortestPrecision means the shortest representation, the same as encoding/json.
+// NaN and Inf are encoded to string "NaN", "+Inf" and "-Inf"
+func (f *Float) format(prec int) []byte {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return []byte(fmt.Sprintf("%.1f", f.f)), nil
+	return formatFloat(f.f, prec)
+}
+
+func formatFloat(f float64, prec int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return []byte(`"NaN"`)
+	case math.IsInf(f, 1):
+		return []byte(`"+Inf"`)
+	case math.IsInf(f, -1):
+		return []byte(`"-Inf"`)
+	case prec >= 0:
+		return strconv.AppendFloat(nil, f, 'f', prec, 64)
+	}
+
+	// same as encoding/json, use exponent for too large or small values
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
 }
 
-func (f *Float) add(delta float64) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	f.f += delta
+func (f *Float) get() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.f
 }
 
 func (f *Float) set(delta float64) {
@@ -311,23 +417,139 @@ func (s *StringSlice) MarshalJSON() ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "[")
+	buf.WriteByte('[')
 	for k, v := range s.s {
 		if k != 0 {
-			fmt.Fprintf(&buf, ",")
+			buf.WriteByte(',')
 		}
-		fmt.Fprintf(&buf, "%q", v)
+		buf.Write(quoteJSON(v))
 	}
-	fmt.Fprintf(&buf, "]")
+	buf.WriteByte(']')
 	return buf.Bytes(), nil
 }
 
-// FloatSlice is used by collect metrics
+// quoteJSON return s encoded as json string
+func quoteJSON(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// encode string never fails
+	enc.Encode(s)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// FloatSlice is used by collect metrics. it is the default Sample of histogram and keeps all values
 type FloatSlice struct {
-	v  []float64
+	v []float64
+	// w is weights of values, nil when all weights are 1
+	w  []int64
 	mu sync.RWMutex
 }
 
+// Update append a value
+func (s *FloatSlice) Update(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v = append(s.v, v)
+	if s.w != nil {
+		s.w = append(s.w, 1)
+	}
+}
+
+// UpdateN append a value observed n times, the value is kept once with the weight
+func (s *FloatSlice) UpdateN(v float64, n int64) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n != 1 && s.w == nil {
+		s.w = make([]int64, len(s.v), len(s.v)+1)
+		for i := range s.w {
+			s.w[i] = 1
+		}
+	}
+	s.v = append(s.v, v)
+	if s.w != nil {
+		s.w = append(s.w, n)
+	}
+}
+
+// Values return sorted copy of all values, weighted values are included once
+func (s *FloatSlice) Values() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sort()
+	res := make([]float64, len(s.v))
+	copy(res, s.v)
+	return res
+}
+
+// WeightedValues return sorted copy of all values and their weights
+func (s *FloatSlice) WeightedValues() ([]float64, []int64) {
+	values, weights := s.weightedValues()
+	if weights == nil {
+		weights = make([]int64, len(values))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	return values, weights
+}
+
+// weightedValues return sorted copy of all values and their weights, weights are nil when all weights are 1
+func (s *FloatSlice) weightedValues() ([]float64, []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sort()
+	values := make([]float64, len(s.v))
+	copy(values, s.v)
+	if s.w == nil {
+		return values, nil
+	}
+	weights := make([]int64, len(s.w))
+	copy(weights, s.w)
+	return values, weights
+}
+
+// sort sort values with their weights, expect to be called with lock
+func (s *FloatSlice) sort() {
+	if s.w == nil {
+		sort.Float64s(s.v)
+		return
+	}
+	sort.Sort(weightedSlice{v: s.v, w: s.w})
+}
+
+// Count return number of values, weighted values are counted by their weights
+func (s *FloatSlice) Count() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.w == nil {
+		return int64(len(s.v))
+	}
+	var n int64
+	for _, w := range s.w {
+		n += w
+	}
+	return n
+}
+
+// weightedSlice is implemented sort.Interface, sort values with their weights
+type weightedSlice struct {
+	v []float64
+	w []int64
+}
+
+func (s weightedSlice) Len() int { return len(s.v) }
+func (s weightedSlice) Less(i, j int) bool {
+	return s.v[i] < s.v[j] || (math.IsNaN(s.v[i]) && !math.IsNaN(s.v[j]))
+}
+func (s weightedSlice) Swap(i, j int) {
+	s.v[i], s.v[j] = s.v[j], s.v[i]
+	s.w[i], s.w[j] = s.w[j], s.w[i]
+}
+
 // Map is used by collect metrics
 type Map struct {
 	v  map[string]struct{}
@@ -340,6 +562,16 @@ func (m *Map) set(s string) {
 	m.v[s] = struct{}{}
 }
 
+func (m *Map) keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s := make([]string, 0, len(m.v))
+	for k := range m.v {
+		s = append(s, k)
+	}
+	return s
+}
+
 // Collector is collect metrics interface
 type Collector interface {
 	// metrics accessor
@@ -356,14 +588,49 @@ type Collector interface {
 
 // SimpleCollector is implemented Collector
 type SimpleCollector struct {
-	metrics map[string]Metrics
-	mu      sync.RWMutex
+	metrics      map[string]Metrics
+	precision    int
+	keyPolicy    KeyPolicy
+	rejectedKeys map[string]struct{}
+	random       func() float64
+	randomMu     sync.RWMutex
+	clock        Clock
+	series       *seriesStore
+	mu           sync.RWMutex
+
+	observers     []*observerEntry
+	subscriptions map[<-chan Update]*subscription
+	observerMu    sync.RWMutex
 }
 
 // NewSimpleCollector return new SimpleCollector
 func NewSimpleCollector() *SimpleCollector {
 	return &SimpleCollector{
-		metrics: make(map[string]Metrics),
+		metrics:      make(map[string]Metrics),
+		precision:    ShortestPrecision,
+		rejectedKeys: make(map[string]struct{}),
+		random:       rand.Float64,
+		clock:        SystemClock,
+	}
+}
+
+// SetPrecision setting digits after the decimal point of encoded metrics values.
+// ShortestPrecision means the shortest representation that round-trips
+func (c *SimpleCollector) SetPrecision(prec int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.precision = prec
+}
+
+// SetClock setting a source of the time, default is SystemClock
+func (c *SimpleCollector) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+	for _, m := range c.metrics {
+		if h, ok := m.(*HistogramMetrics); ok {
+			setSampleClock(h.value, clock)
+		}
 	}
 }
 
@@ -377,20 +644,19 @@ func (c *SimpleCollector) GetMetrics(key string) ([]byte, error) {
 		return nil, ErrNotFoundMetrics
 	}
 
-	// need sort keys?
-	switch m := m.(type) {
-	case *HistogramMetrics:
-		return m.MarshalJSONWithOrder()
-	default:
-		return json.Marshal(m.Aggregate())
-	}
+	return marshalJSONWithOrder(m.Aggregate(), c.precision)
 }
 
 // GetMetricsKeys returns keeps metrics keys
 func (c *SimpleCollector) GetMetricsKeys() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	res := make([]string, 0)
+	return c.sortedKeys()
+}
+
+// sortedKeys return sorted metrics keys, expect to be called with lock
+func (c *SimpleCollector) sortedKeys() []string {
+	res := make([]string, 0, len(c.metrics))
 	for k := range c.metrics {
 		res = append(res, k)
 	}
@@ -401,7 +667,30 @@ func (c *SimpleCollector) GetMetricsKeys() []string {
 // Add add count for CounterMetrics
 func (c *SimpleCollector) Add(key string, delta float64) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	key, err := c.add(key, delta, nil)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeCounter, Value: delta})
+	}
+}
+
+// add expect to be called with lock
+func (c *SimpleCollector) add(key string, delta float64, e *Exemplar) (string, error) {
+	v, err := c.counterMetrics(key)
+	if err != nil {
+		return "", err
+	}
+	v.add(delta, e)
+	return v.key, nil
+}
+
+// counterMetrics return CounterMetrics of the key, add the key when not exist.
+// expect to be called with lock
+func (c *SimpleCollector) counterMetrics(key string) (*CounterMetrics, error) {
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
 
 	// add key
 	if _, dup := c.metrics[key]; !dup {
@@ -412,15 +701,40 @@ func (c *SimpleCollector) Add(key string, delta float64) {
 	}
 
 	// incremental counter, ignore otherwise
-	if v, ok := c.metrics[key].(*CounterMetrics); ok {
-		v.value.add(delta)
+	v, ok := c.metrics[key].(*CounterMetrics)
+	if !ok {
+		return nil, ErrMismatchMetricType
 	}
+	return v, nil
 }
 
 // Gauge set metrics for GaugeMetrics
 func (c *SimpleCollector) Gauge(key string, delta float64) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	key, err := c.gauge(key, delta)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeGauge, Value: delta})
+	}
+}
+
+// gauge expect to be called with lock
+func (c *SimpleCollector) gauge(key string, delta float64) (string, error) {
+	v, err := c.gaugeMetrics(key)
+	if err != nil {
+		return "", err
+	}
+	v.set(delta, c.clock.Now())
+	return v.key, nil
+}
+
+// gaugeMetrics return GaugeMetrics of the key, add the key when not exist.
+// expect to be called with lock
+func (c *SimpleCollector) gaugeMetrics(key string) (*GaugeMetrics, error) {
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
 
 	// add key
 	if _, dup := c.metrics[key]; !dup {
@@ -430,16 +744,69 @@ func (c *SimpleCollector) Gauge(key string, delta float64) {
 		}
 	}
 
-	// incremental counter, ignore otherwise
-	if v, ok := c.metrics[key].(*GaugeMetrics); ok {
-		v.value.set(delta)
+	// set gauge, ignore otherwise
+	v, ok := c.metrics[key].(*GaugeMetrics)
+	if !ok {
+		return nil, ErrMismatchMetricType
 	}
+	return v, nil
 }
 
 // Histogram add metrics for Histogram
 func (c *SimpleCollector) Histogram(key string, delta float64) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	key, err := c.histogram(key, delta, nil)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeHistogram, Value: delta})
+	}
+}
+
+// HistogramN add metrics for Histogram, the value is observed n times.
+// values are kept once with the weight by FloatSlice and HDRSample, offered at most size times
+// by ExpDecaySample, and added n times to other Sample
+func (c *SimpleCollector) HistogramN(key string, delta float64, n int64) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	key, err := c.histogramN(key, delta, n)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeHistogram, Value: delta, Count: n})
+	}
+}
+
+// histogramN expect to be called with lock
+func (c *SimpleCollector) histogramN(key string, delta float64, n int64) (string, error) {
+	v, err := c.histogramMetrics(key)
+	if err != nil {
+		return "", err
+	}
+	updateN(v.value, delta, n)
+	return v.key, nil
+}
+
+// histogram expect to be called with lock
+func (c *SimpleCollector) histogram(key string, delta float64, e *Exemplar) (string, error) {
+	v, err := c.histogramMetrics(key)
+	if err != nil {
+		return "", err
+	}
+	v.value.Update(delta)
+	if e != nil {
+		v.exemplars.add(e)
+	}
+	return v.key, nil
+}
+
+// histogramMetrics return HistogramMetrics of the key, add the key when not exist.
+// expect to be called with lock
+func (c *SimpleCollector) histogramMetrics(key string) (*HistogramMetrics, error) {
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
 
 	// add key
 	if _, dup := c.metrics[key]; !dup {
@@ -452,15 +819,61 @@ func (c *SimpleCollector) Histogram(key string, delta float64) {
 	}
 
 	// add histogram, ignore otherwise
-	if v, ok := c.metrics[key].(*HistogramMetrics); ok {
-		v.value.v = append(v.value.v, delta)
+	v, ok := c.metrics[key].(*HistogramMetrics)
+	if !ok {
+		return nil, ErrMismatchMetricType
+	}
+	return v, nil
+}
+
+// RegisterHistogram add HistogramMetrics using specific Sample, decaying Sample uses Clock of the collector.
+// Histogram() add metrics with FloatSlice when key is not registered
+func (c *SimpleCollector) RegisterHistogram(key string, s Sample) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+
+	if _, dup := c.metrics[key]; dup {
+		return ErrAlreadyExistMetrics
+	}
+	setSampleClock(s, c.clock)
+	c.metrics[key] = &HistogramMetrics{
+		key:   key,
+		value: s,
 	}
+	return nil
 }
 
 // Set add metrics for Set
 func (c *SimpleCollector) Set(key string, delta string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	key, err := c.set(key, delta)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeSet, Values: []string{delta}})
+	}
+}
+
+// set expect to be called with lock
+func (c *SimpleCollector) set(key string, delta string) (string, error) {
+	v, err := c.setMetrics(key)
+	if err != nil {
+		return "", err
+	}
+	v.value.set(delta)
+	return v.key, nil
+}
+
+// setMetrics return SetMetrics of the key, add the key when not exist.
+// expect to be called with lock
+func (c *SimpleCollector) setMetrics(key string) (*SetMetrics, error) {
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
 
 	// add key
 	if _, dup := c.metrics[key]; !dup {
@@ -473,28 +886,39 @@ func (c *SimpleCollector) Set(key string, delta string) {
 	}
 
 	// add set, ignore otherwise
-	if v, ok := c.metrics[key].(*SetMetrics); ok {
-		v.value.set(delta)
+	v, ok := c.metrics[key].(*SetMetrics)
+	if !ok {
+		return nil, ErrMismatchMetricType
 	}
+	return v, nil
 }
 
 // Snapshot add metrics for Snapshot
 func (c *SimpleCollector) Snapshot(key string, deltas []string) {
+	if key, err := c.snapshot(key, deltas); err == nil {
+		c.notify(Event{Key: key, Type: TypeSnapshot, Values: deltas})
+	}
+}
+
+func (c *SimpleCollector) snapshot(key string, deltas []string) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return "", ErrInvalidKey
+	}
 
 	// add key and ignore existing key
-	c.metrics[key] = &SnapshotMetrics{
+	v := &SnapshotMetrics{
 		key: key,
 		value: &Map{
 			v: make(map[string]struct{}),
 		},
+		updated: c.clock.Now(),
 	}
-
-	// add Snapshot, ignore otherwise
-	if v, ok := c.metrics[key].(*SnapshotMetrics); ok {
-		for _, d := range deltas {
-			v.value.set(d)
-		}
+	for _, d := range deltas {
+		v.value.v[d] = struct{}{}
 	}
+	c.metrics[key] = v
+	return key, nil
 }
@@ -1,6 +1,7 @@
 package collect
 
 import (
+	"math"
 	"reflect"
 	"testing"
 )
@@ -12,9 +13,9 @@ func TestCounter(t *testing.T) {
 		value  float64
 		expect []byte
 	}{
-		{"a", 1, []byte("1.0")},
-		{"a", 2, []byte("3.0")},
-		{"b", 2, []byte("2.0")},
+		{"a", 1, []byte("1")},
+		{"a", 2, []byte("3")},
+		{"b", 2, []byte("2")},
 	}
 	for i, c := range cases {
 		sc.Add(c.key, c.value)
@@ -39,9 +40,9 @@ func TestGauge(t *testing.T) {
 		value  float64
 		expect []byte
 	}{
-		{"a", 1, []byte("1.0")},
-		{"a", 2, []byte("2.0")},
-		{"b", 1, []byte("1.0")},
+		{"a", 1, []byte("1")},
+		{"a", 2, []byte("2")},
+		{"b", 1, []byte("1")},
 	}
 	for i, c := range cases {
 		sc.Gauge(c.key, c.value)
@@ -72,11 +73,11 @@ func TestHistogram(t *testing.T) {
 				5,
 			},
 			map[string][]byte{
-				"a.count":        []byte("1.0"),
-				"a.avg":          []byte("5.0"),
-				"a.max":          []byte("5.0"),
-				"a.median":       []byte("5.0"),
-				"a.95percentile": []byte("0.0"),
+				"a.count":        []byte("1"),
+				"a.avg":          []byte("5"),
+				"a.max":          []byte("5"),
+				"a.median":       []byte("5"),
+				"a.95percentile": []byte("0"),
 			},
 		},
 		{
@@ -85,11 +86,11 @@ func TestHistogram(t *testing.T) {
 				1, 2,
 			},
 			map[string][]byte{
-				"b.count":        []byte("2.0"),
+				"b.count":        []byte("2"),
 				"b.avg":          []byte("1.5"),
-				"b.max":          []byte("2.0"),
-				"b.median":       []byte("2.0"),
-				"b.95percentile": []byte("1.9"),
+				"b.max":          []byte("2"),
+				"b.median":       []byte("2"),
+				"b.95percentile": []byte("1.95"),
 			},
 		},
 		{
@@ -98,11 +99,11 @@ func TestHistogram(t *testing.T) {
 				10, 5, 5, 2, 3, 40, 10, 10, 10, 9,
 			},
 			map[string][]byte{
-				"c.count":        []byte("10.0"),
+				"c.count":        []byte("10"),
 				"c.avg":          []byte("10.4"),
-				"c.max":          []byte("40.0"),
-				"c.median":       []byte("10.0"),
-				"c.95percentile": []byte("26.5"),
+				"c.max":          []byte("40"),
+				"c.median":       []byte("10"),
+				"c.95percentile": []byte("26.499999999999968"),
 			},
 		},
 	}
@@ -225,7 +226,7 @@ func TestMix(t *testing.T) {
 	c.Snapshot("ss", []string{"b", "c"})
 
 	// expect: choosable a metrics in mixed metrics collector
-	expectGauge := []byte("5.0")
+	expectGauge := []byte("5")
 	agg := c.metrics["g"].Aggregate()
 	got, err := agg["g"].MarshalJSON()
 	if err != nil {
@@ -270,7 +271,7 @@ func TestGetMetrics(t *testing.T) {
 	c.Set("s", "b")
 
 	// counter
-	eCounter := []byte(`{"c":1.0}`)
+	eCounter := []byte(`{"c":1}`)
 	mCounter, err := c.GetMetrics("c")
 	if err != nil {
 		t.Fatalf("want no error, got %v", err)
@@ -281,7 +282,7 @@ func TestGetMetrics(t *testing.T) {
 
 	// histogram
 	// note: expect sorted metrics
-	eHistogram := []byte(`{"h.95percentile":0.0,"h.avg":1.0,"h.count":1.0,"h.max":1.0,"h.median":1.0}`)
+	eHistogram := []byte(`{"h.95percentile":0,"h.avg":1,"h.count":1,"h.max":1,"h.median":1}`)
 	mHistogram, err := c.GetMetrics("h")
 	if err != nil {
 		t.Fatalf("want no error, got %v", err)
@@ -315,3 +316,59 @@ func TestGetMetricsKeys(t *testing.T) {
 		t.Errorf("want %v, got %v", expect, got)
 	}
 }
+
+func TestFloatFormat(t *testing.T) {
+	cases := []struct {
+		value  float64
+		prec   int
+		expect []byte
+	}{
+		{0, ShortestPrecision, []byte("0")},
+		{0.0042, ShortestPrecision, []byte("0.0042")},
+		{-1.5, ShortestPrecision, []byte("-1.5")},
+		{1499763733746146600, ShortestPrecision, []byte("1499763733746146600")},
+		{1e21, ShortestPrecision, []byte("1e+21")},
+		{1e-7, ShortestPrecision, []byte("1e-7")},
+		{0.0042, 1, []byte("0.0")},
+		{2, 2, []byte("2.00")},
+		{math.NaN(), ShortestPrecision, []byte(`"NaN"`)},
+		{math.Inf(1), 1, []byte(`"+Inf"`)},
+		{math.Inf(-1), ShortestPrecision, []byte(`"-Inf"`)},
+	}
+	for i, c := range cases {
+		f := &Float{f: c.value}
+		if got := f.format(c.prec); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("#%d: want %s, got %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestGetMetricsWithPrecision(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetPrecision(1)
+	c.Add("c", 1)
+	c.Gauge("g", math.Inf(1))
+	c.Histogram("h", 1)
+	if err := c.RegisterHistogram("empty", NewExpDecaySample(10, 0.015)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"c", []byte(`{"c":1.0}`)},
+		{"empty", []byte(`{"empty.95percentile":0.0,"empty.avg":0.0,"empty.count":0.0,"empty.max":0.0,"empty.median":0.0}`)},
+		{"g", []byte(`{"g":"+Inf"}`)},
+		{"h", []byte(`{"h.95percentile":0.0,"h.avg":1.0,"h.count":1.0,"h.max":1.0,"h.median":1.0}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+)
+
+// contextKey is a key type of context values
+type contextKey int
+
+// Enum of contextKey
+const (
+	collectorContextKey contextKey = iota
+	labelsContextKey
+	traceIDContextKey
+)
+
+// NewContext return a copy of ctx with the Collector
+func NewContext(ctx context.Context, c Collector) context.Context {
+	return context.WithValue(ctx, collectorContextKey, c)
+}
+
+// FromContext return the Collector in ctx
+func FromContext(ctx context.Context) (Collector, bool) {
+	c, ok := ctx.Value(collectorContextKey).(Collector)
+	return c, ok && c != nil
+}
+
+// WithLabels return a copy of ctx with default labels, merged with labels in ctx
+// label names are validated by KeyPolicy of the collector when recorded, invalid names are rejected
+// as a part of the key
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range LabelsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsContextKey, merged)
+}
+
+// LabelsFromContext return default labels in ctx
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsContextKey).(map[string]string)
+	return labels
+}
+
+// WithTraceID return a copy of ctx with trace ID, it is recorded as exemplar
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey, traceID)
+}
+
+// TraceIDFromContext return trace ID in ctx
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDContextKey).(string)
+	return id
+}
+
+// exemplarCollector is a Collector supported exemplars
+type exemplarCollector interface {
+	AddWithExemplar(string, float64, Exemplar)
+	HistogramWithExemplar(string, float64, Exemplar)
+}
+
+// AddCtx add count to the Collector in ctx, using default labels and trace ID in ctx
+func AddCtx(ctx context.Context, key string, delta float64) {
+	c, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	key = LabeledKey(key, LabelsFromContext(ctx))
+	if ec, ok := c.(exemplarCollector); ok {
+		if id := TraceIDFromContext(ctx); id != "" {
+			ec.AddWithExemplar(key, delta, Exemplar{TraceID: id})
+			return
+		}
+	}
+	c.Add(key, delta)
+}
+
+// GaugeCtx set gauge to the Collector in ctx, using default labels in ctx
+func GaugeCtx(ctx context.Context, key string, delta float64) {
+	if c, ok := FromContext(ctx); ok {
+		c.Gauge(LabeledKey(key, LabelsFromContext(ctx)), delta)
+	}
+}
+
+// HistogramCtx add histogram to the Collector in ctx, using default labels and trace ID in ctx
+func HistogramCtx(ctx context.Context, key string, delta float64) {
+	c, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	key = LabeledKey(key, LabelsFromContext(ctx))
+	if ec, ok := c.(exemplarCollector); ok {
+		if id := TraceIDFromContext(ctx); id != "" {
+			ec.HistogramWithExemplar(key, delta, Exemplar{TraceID: id})
+			return
+		}
+	}
+	c.Histogram(key, delta)
+}
+
+// SetCtx add set to the Collector in ctx, using default labels in ctx
+func SetCtx(ctx context.Context, key string, delta string) {
+	if c, ok := FromContext(ctx); ok {
+		c.Set(LabeledKey(key, LabelsFromContext(ctx)), delta)
+	}
+}
+
+// SnapshotCtx add snapshot to the Collector in ctx, using default labels in ctx
+func SnapshotCtx(ctx context.Context, key string, deltas []string) {
+	if c, ok := FromContext(ctx); ok {
+		c.Snapshot(LabeledKey(key, LabelsFromContext(ctx)), deltas)
+	}
+}
+
+// labelValueReplacer escapes label values, the same as Prometheus
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// LabeledKey return key with labels sorted by names, e.g. `key{method="GET",route="/"}`
+func LabeledKey(key string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return key
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString(key)
+	buf.WriteByte('{')
+	for i, k := range names {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(k)
+		buf.WriteString(`="`)
+		labelValueReplacer.WriteString(&buf, labels[k])
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// splitLabeledKey return a name and labels part of the key made by LabeledKey
+func splitLabeledKey(key string) (string, string) {
+	i := strings.IndexByte(key, '{')
+	if i < 0 || !strings.HasSuffix(key, "}") {
+		return key, ""
+	}
+	return key[:i], key[i:]
+}
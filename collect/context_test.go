@@ -0,0 +1,71 @@
+package collect
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLabeledKey(t *testing.T) {
+	cases := []struct {
+		key    string
+		labels map[string]string
+		expect string
+	}{
+		{"a", nil, "a"},
+		{"a", map[string]string{"route": "/", "method": "GET"}, `a{method="GET",route="/"}`},
+		{"a", map[string]string{"v": "\"\\\n"}, `a{v="\"\\\n"}`},
+	}
+	for i, c := range cases {
+		if got := LabeledKey(c.key, c.labels); got != c.expect {
+			t.Errorf("#%d: want %s, got %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestContextRecording(t *testing.T) {
+	c := NewSimpleCollector()
+	ctx := NewContext(context.Background(), c)
+	ctx = WithLabels(ctx, map[string]string{"tenant": "a"})
+	ctx = WithLabels(ctx, map[string]string{"route": "/"})
+
+	AddCtx(ctx, "c", 1)
+	GaugeCtx(ctx, "g", 1)
+	HistogramCtx(WithTraceID(ctx, "abc"), "h", 1)
+	SetCtx(ctx, "s", "a")
+	SnapshotCtx(ctx, "ss", []string{"a"})
+
+	expect := []string{
+		`c{route="/",tenant="a"}`,
+		`g{route="/",tenant="a"}`,
+		`h{route="/",tenant="a"}`,
+		`ss{route="/",tenant="a"}`,
+		`s{route="/",tenant="a"}`,
+	}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("want keys %v, got %v", expect, got)
+	}
+	agg := c.metrics[`h{route="/",tenant="a"}`].Aggregate()
+	e, ok := agg[`h{route="/",tenant="a"}.median.exemplar`].(*Exemplar)
+	if !ok || e.TraceID != "abc" {
+		t.Errorf("want exemplar with trace id, got %v", agg)
+	}
+
+	// expect: ignored when collector is not in context
+	AddCtx(context.Background(), "c", 1)
+	if _, ok := FromContext(context.Background()); ok {
+		t.Errorf("want no collector in context")
+	}
+}
+
+func TestContextKeyPolicy(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetKeyPolicy(PrometheusKeyPolicy(KeyModeSanitize))
+	ctx := WithLabels(NewContext(context.Background(), c), map[string]string{"route": "/a.b"})
+
+	AddCtx(ctx, "http.requests", 1)
+	expect := []string{`http_requests{route="/a.b"}`}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want keys %v, got %v", expect, got)
+	}
+}
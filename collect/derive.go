@@ -0,0 +1,469 @@
+package collect
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// about expression errors
+var (
+	ErrInvalidExpression = errors.New("invalid expression")
+)
+
+// DerivedMetrics is implemented Metrics for Derive, the value is evaluated from the expression
+type DerivedMetrics struct {
+	key  string
+	expr string
+	node exprNode
+	// c is the collector to resolve keys in the expression
+	c *SimpleCollector
+}
+
+// newDerivedMetrics return DerivedMetrics of the expression, nil when the expression is invalid
+func newDerivedMetrics(key, expr string, c *SimpleCollector) *DerivedMetrics {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return nil
+	}
+	return &DerivedMetrics{
+		key:  key,
+		expr: expr,
+		node: node,
+		c:    c,
+	}
+}
+
+// Aggregate return key and evaluated value, expect to be called with lock of the collector
+func (m *DerivedMetrics) Aggregate() map[string]Data {
+	var now time.Time
+	if m.c != nil {
+		now = m.c.clock.Now()
+	}
+	return map[string]Data{
+		m.key: &Float{f: m.evaluate(map[string]bool{}, now)},
+	}
+}
+
+// GetType return MetricType
+func (m *DerivedMetrics) GetType() MetricType {
+	return TypeDerived
+}
+
+// Merge is nothing to do, DerivedMetrics has no recorded values
+func (m *DerivedMetrics) Merge(other Metrics) error {
+	if _, ok := other.(*DerivedMetrics); !ok {
+		return ErrMismatchMetricType
+	}
+	return nil
+}
+
+// Reset clear previous values of rate()
+func (m *DerivedMetrics) Reset() {
+	m.node.reset()
+}
+
+// evaluate return the value, NaN when the expression refers itself
+func (m *DerivedMetrics) evaluate(visiting map[string]bool, now time.Time) float64 {
+	if visiting[m.key] || m.c == nil {
+		return math.NaN()
+	}
+	visiting[m.key] = true
+	defer delete(visiting, m.key)
+	return m.node.eval(&exprEnv{
+		now: now,
+		lookup: func(name string) float64 {
+			return m.c.lookupValue(name, visiting, now)
+		},
+	})
+}
+
+// lookupValue return the aggregated value of the name, e.g. "requests" or "latency.avg".
+// return 0 when not found, expect to be called with lock
+func (c *SimpleCollector) lookupValue(name string, visiting map[string]bool, now time.Time) float64 {
+	for key := name; ; {
+		if m, ok := c.metrics[key]; ok {
+			if d, ok := m.(*DerivedMetrics); ok {
+				if key == name {
+					return d.evaluate(visiting, now)
+				}
+			} else if f, ok := m.Aggregate()[name].(*Float); ok {
+				return f.get()
+			}
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			return 0
+		}
+		key = key[:i]
+	}
+}
+
+// Derive add DerivedMetrics evaluated from the expression when metrics are read, e.g. "errors / requests".
+// the expression supports numbers, keys, + - * / and parentheses, and functions
+// rate(x) is increase of x per second since the previous evaluation, 0 at the first time,
+// and ratio(x, y) is x / y, 0 when y is 0.
+// keys are resolved to values of aggregated keys, e.g. "latency.95percentile", and 0 when not found
+func (c *SimpleCollector) Derive(key string, expr string) error {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+
+	if _, dup := c.metrics[key]; dup {
+		return ErrAlreadyExistMetrics
+	}
+	c.metrics[key] = &DerivedMetrics{
+		key:  key,
+		expr: expr,
+		node: node,
+		c:    c,
+	}
+	return nil
+}
+
+// exprEnv is an environment to evaluate expressions
+type exprEnv struct {
+	now    time.Time
+	lookup func(string) float64
+}
+
+// exprNode is a node of parsed expression
+type exprNode interface {
+	eval(env *exprEnv) float64
+	// reset clear states of rate()
+	reset()
+}
+
+type numberNode float64
+
+func (n numberNode) eval(env *exprEnv) float64 { return float64(n) }
+func (n numberNode) reset()                    {}
+
+type keyNode string
+
+func (n keyNode) eval(env *exprEnv) float64 { return env.lookup(string(n)) }
+func (n keyNode) reset()                    {}
+
+type negNode struct {
+	x exprNode
+}
+
+func (n *negNode) eval(env *exprEnv) float64 { return -n.x.eval(env) }
+func (n *negNode) reset()                    { n.x.reset() }
+
+type binaryNode struct {
+	op   byte
+	x, y exprNode
+}
+
+func (n *binaryNode) eval(env *exprEnv) float64 {
+	x, y := n.x.eval(env), n.y.eval(env)
+	switch n.op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	default:
+		return x / y
+	}
+}
+
+func (n *binaryNode) reset() {
+	n.x.reset()
+	n.y.reset()
+}
+
+type ratioNode struct {
+	x, y exprNode
+}
+
+func (n *ratioNode) eval(env *exprEnv) float64 {
+	x, y := n.x.eval(env), n.y.eval(env)
+	if y == 0 {
+		return 0
+	}
+	return x / y
+}
+
+func (n *ratioNode) reset() {
+	n.x.reset()
+	n.y.reset()
+}
+
+type rateNode struct {
+	x      exprNode
+	last   float64
+	lastAt time.Time
+	// rate is the last computed rate, returned for evaluations at the same time
+	rate float64
+	mu   sync.Mutex
+}
+
+func (n *rateNode) eval(env *exprEnv) float64 {
+	v := n.x.eval(env)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	last, lastAt := n.last, n.lastAt
+	elapsed := env.now.Sub(lastAt).Seconds()
+	if elapsed <= 0 && !lastAt.IsZero() {
+		// evaluated at the same time, keep the previous rate
+		return n.rate
+	}
+	n.last, n.lastAt = v, env.now
+	if lastAt.IsZero() {
+		return 0
+	}
+	// counter is reset
+	if v < last {
+		last = 0
+	}
+	n.rate = (v - last) / elapsed
+	return n.rate
+}
+
+func (n *rateNode) reset() {
+	n.x.reset()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.last = 0
+	n.lastAt = time.Time{}
+	n.rate = 0
+}
+
+// exprParser is a recursive descent parser of expressions
+type exprParser struct {
+	s   string
+	pos int
+}
+
+// parseExpr return parsed expression
+func parseExpr(s string) (exprNode, error) {
+	p := &exprParser{s: s}
+	n, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return n, nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return errors.Wrapf(ErrInvalidExpression, "%s at %d of %q", errors.Errorf(format, args...), p.pos, p.s)
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// consume skip spaces and the byte c if it is next
+func (p *exprParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseSum parse term (('+'|'-') term)*
+func (p *exprParser) parseSum() (exprNode, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.consume('+'):
+			op = '+'
+		case p.consume('-'):
+			op = '-'
+		default:
+			return x, nil
+		}
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+}
+
+// parseTerm parse unary (('*'|'/') unary)*
+func (p *exprParser) parseTerm() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.consume('*'):
+			op = '*'
+		case p.consume('/'):
+			op = '/'
+		default:
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+}
+
+// parseUnary parse '-' unary | primary
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.consume('-') {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parse number | key | function '(' args ')' | '(' sum ')'
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.consume('(') {
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(')') {
+			return nil, p.errorf("missing )")
+		}
+		return x, nil
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end")
+	}
+	c := p.s[p.pos]
+	switch {
+	case isASCIIDigit(rune(c)) || c == '.':
+		return p.parseNumber()
+	case isASCIILetter(rune(c)) || c == '_':
+		name := p.parseKey()
+		if p.consume('(') {
+			return p.parseFunc(name)
+		}
+		return keyNode(name), nil
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *exprParser) parseNumber() (exprNode, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if isASCIIDigit(rune(c)) || c == '.' {
+			p.pos++
+			continue
+		}
+		// exponent
+		if (c == 'e' || c == 'E') && p.pos+1 < len(p.s) {
+			p.pos++
+			if p.s[p.pos] == '+' || p.s[p.pos] == '-' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %s", p.s[start:p.pos])
+	}
+	return numberNode(v), nil
+}
+
+// parseKey return a key, labels made by LabeledKey are included
+func (p *exprParser) parseKey() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := rune(p.s[p.pos])
+		if isASCIILetter(c) || isASCIIDigit(c) || c == '_' || c == '.' || c == ':' {
+			p.pos++
+			continue
+		}
+		if c == '{' {
+			p.skipLabels()
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+// skipLabels skip `{k="v",...}`, quoted values may contain '}'
+func (p *exprParser) skipLabels() {
+	quoted := false
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case quoted && c == '\\':
+			p.pos++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '}':
+			return
+		}
+	}
+}
+
+// parseFunc parse arguments of the function after '('
+func (p *exprParser) parseFunc(name string) (exprNode, error) {
+	args := make([]exprNode, 0, 2)
+	if !p.consume(')') {
+		for {
+			x, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, x)
+			if p.consume(')') {
+				break
+			}
+			if !p.consume(',') {
+				return nil, p.errorf("missing )")
+			}
+		}
+	}
+
+	switch name {
+	case "rate":
+		if len(args) != 1 {
+			return nil, p.errorf("rate() takes 1 argument")
+		}
+		return &rateNode{x: args[0]}, nil
+	case "ratio":
+		if len(args) != 2 {
+			return nil, p.errorf("ratio() takes 2 arguments")
+		}
+		return &ratioNode{x: args[0], y: args[1]}, nil
+	}
+	return nil, p.errorf("unknown function %s", name)
+}
@@ -0,0 +1,211 @@
+package collect
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func createDerivedCollector(t *testing.T) *SimpleCollector {
+	c := NewSimpleCollector()
+	c.Add("errors", 5)
+	c.Add("requests", 20)
+	c.Add("hits", 3)
+	c.Add("misses", 1)
+	c.Add(`http{code="500"}`, 2)
+	for _, v := range []float64{1, 2, 3} {
+		c.Histogram("latency", v)
+	}
+	for k, expr := range map[string]string{
+		"error_ratio": "errors / requests",
+		"hit_rate":    "hits / (hits+misses)",
+		"percent":     "error_ratio * 100",
+		"arith":       "-errors + 2 * 3 - latency.max / 2",
+		"safe":        "ratio(errors, unknown)",
+		"unsafe":      "errors / unknown",
+		"labeled":     `http{code="500"} * 1.5e1`,
+	} {
+		if err := c.Derive(k, expr); err != nil {
+			t.Fatalf("want no error, got %v", err)
+		}
+	}
+	return c
+}
+
+func TestDerive(t *testing.T) {
+	c := createDerivedCollector(t)
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"error_ratio", []byte(`{"error_ratio":0.25}`)},
+		{"hit_rate", []byte(`{"hit_rate":0.75}`)},
+		{"percent", []byte(`{"percent":25}`)},
+		{"arith", []byte(`{"arith":-0.5}`)},
+		{"safe", []byte(`{"safe":0}`)},
+		{"unsafe", []byte(`{"unsafe":"+Inf"}`)},
+		{"labeled", []byte(`{"labeled":30}`)},
+	}
+	for i, tc := range cases {
+		if got := c.metrics[tc.key].GetType(); got != TypeDerived {
+			t.Fatalf("#%d: want type %s, got %s", i, TypeDerived, got)
+		}
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+
+	keys := c.GetMetricsKeys()
+	for _, k := range []string{"error_ratio", "hit_rate"} {
+		found := false
+		for _, v := range keys {
+			found = found || v == k
+		}
+		if !found {
+			t.Errorf("want key %s in %v", k, keys)
+		}
+	}
+	if err := c.Derive("errors", "1"); err != ErrAlreadyExistMetrics {
+		t.Errorf("want error %v, got %v", ErrAlreadyExistMetrics, err)
+	}
+}
+
+func TestDeriveCycle(t *testing.T) {
+	c := NewSimpleCollector()
+	if err := c.Derive("a", "b + 1"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.Derive("b", "a"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := []byte(`{"a":"NaN"}`)
+	got, err := c.GetMetrics("a")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestDeriveRate(t *testing.T) {
+	c := NewSimpleCollector()
+	if err := c.Derive("error_rate", "rate(errors) / 2"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	m := c.metrics["error_rate"].(*DerivedMetrics)
+
+	base := time.Unix(1500000000, 0)
+	cases := []struct {
+		add     float64
+		elapsed time.Duration
+		expect  float64
+	}{
+		// no previous value
+		{10, 0, 0},
+		{20, 10 * time.Second, 1},
+		// evaluated at the same time
+		{0, 10 * time.Second, 1},
+		{40, 20 * time.Second, 2},
+	}
+	for i, tc := range cases {
+		c.Add("errors", tc.add)
+		c.mu.RLock()
+		got := m.evaluate(map[string]bool{}, base.Add(tc.elapsed))
+		c.mu.RUnlock()
+		if got != tc.expect {
+			t.Errorf("#%d: want %v, got %v", i, tc.expect, got)
+		}
+	}
+
+	m.Reset()
+	c.mu.RLock()
+	got := m.evaluate(map[string]bool{}, base.Add(time.Hour))
+	c.mu.RUnlock()
+	if got != 0 {
+		t.Errorf("want 0 after reset, got %v", got)
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	cases := []string{
+		"",
+		"a +",
+		"(a + b",
+		"a b",
+		"1..2",
+		"rate(a, b)",
+		"ratio(a)",
+		"unknown(a)",
+		"a # b",
+	}
+	for i, tc := range cases {
+		if _, err := parseExpr(tc); errors.Cause(err) != ErrInvalidExpression {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidExpression, err)
+		}
+	}
+	c := NewSimpleCollector()
+	if err := c.Derive("a", "b +"); errors.Cause(err) != ErrInvalidExpression {
+		t.Errorf("want error %v, got %v", ErrInvalidExpression, err)
+	}
+}
+
+func TestSaveAndLoadDerived(t *testing.T) {
+	src := createDerivedCollector(t)
+	var buf bytes.Buffer
+	if err := src.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	dst := NewSimpleCollector()
+	if err := dst.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	merged := NewSimpleCollector()
+	if err := merged.Merge(src); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	expect := []byte(`{"hit_rate":0.75}`)
+	for i, c := range []*SimpleCollector{dst, merged} {
+		got, err := c.GetMetrics("hit_rate")
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %s, got %s", i, expect, got)
+		}
+	}
+}
+
+func TestDeriveRateSameTime(t *testing.T) {
+	c := NewSimpleCollector()
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	c.SetClock(clock)
+	if err := c.Derive("request_rate", "rate(requests)"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	c.Add("requests", 10)
+	c.GetMetrics("request_rate")
+	clock.Advance(10 * time.Second)
+	c.Add("requests", 50)
+
+	// every reader at the same time get the same rate
+	expect := []byte(`{"request_rate":5}`)
+	for i := 0; i < 2; i++ {
+		got, err := c.GetMetrics("request_rate")
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %s, got %s", i, expect, got)
+		}
+	}
+}
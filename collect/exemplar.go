@@ -0,0 +1,197 @@
+package collect
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxExemplarLabelsLength is maximum length of exemplar labels, the same as OpenMetrics
+const maxExemplarLabelsLength = 128
+
+// maxExemplars is maximum number of exemplars kept by a histogram
+const maxExemplars = 32
+
+// exemplarTraceIDLabel is a label name of Exemplar.TraceID in encoded exemplar
+const exemplarTraceIDLabel = "trace_id"
+
+// Exemplar is a reference to a concrete observation, e.g. a trace
+type Exemplar struct {
+	TraceID   string
+	Labels    map[string]string
+	Timestamp time.Time
+	// Value is an observed value, filled when recorded
+	Value float64
+}
+
+// MarshalJSON return specific encoded json, like an OpenMetrics exemplar
+func (e *Exemplar) MarshalJSON() ([]byte, error) {
+	labels := e.labels()
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"labels":{`)
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(quoteJSON(k))
+		buf.WriteByte(':')
+		buf.Write(quoteJSON(labels[k]))
+	}
+	buf.WriteString(`},"timestamp":`)
+	buf.Write(formatFloat(float64(e.Timestamp.UnixNano())/float64(time.Second), ShortestPrecision))
+	buf.WriteString(`,"value":`)
+	buf.Write(formatFloat(e.Value, ShortestPrecision))
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// labels return Labels with TraceID
+func (e *Exemplar) labels() map[string]string {
+	labels := make(map[string]string, len(e.Labels)+1)
+	for k, v := range e.Labels {
+		labels[k] = v
+	}
+	if e.TraceID != "" {
+		labels[exemplarTraceIDLabel] = e.TraceID
+	}
+	return labels
+}
+
+// valid reports whether total length of labels is within maxExemplarLabelsLength
+func (e *Exemplar) valid() bool {
+	var length int
+	for k, v := range e.labels() {
+		length += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	return length <= maxExemplarLabelsLength
+}
+
+// newExemplar return a copy of e observed v, or nil when e is invalid
+func newExemplar(e Exemplar, v float64, now time.Time) *Exemplar {
+	if !e.valid() {
+		return nil
+	}
+	labels := make(map[string]string, len(e.Labels))
+	for k, v := range e.Labels {
+		labels[k] = v
+	}
+	e.Labels = labels
+	e.Value = v
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now
+	}
+	return &e
+}
+
+// exemplarBucket return power of 2 bucket of the value, values less than or equal to 0 are in the same bucket
+func exemplarBucket(v float64) int {
+	switch {
+	case !(v > 0):
+		return math.MinInt32
+	case math.IsInf(v, 1):
+		return math.MaxInt32
+	}
+	_, exp := math.Frexp(v)
+	return exp
+}
+
+// exemplarBuckets keeps the latest exemplar of each bucket of values when it is recorded,
+// so exemplars of rare values are not evicted by frequent values.
+// the oldest exemplar is evicted when over maxExemplars buckets
+type exemplarBuckets struct {
+	v  map[int]*Exemplar
+	mu sync.RWMutex
+}
+
+func (r *exemplarBuckets) add(e *Exemplar) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.v == nil {
+		r.v = make(map[int]*Exemplar)
+	}
+	b := exemplarBucket(e.Value)
+	old, ok := r.v[b]
+	if ok {
+		if !e.Timestamp.Before(old.Timestamp) {
+			r.v[b] = e
+		}
+		return
+	}
+	if len(r.v) >= maxExemplars {
+		oldest := 0
+		for k, v := range r.v {
+			if old == nil || v.Timestamp.Before(old.Timestamp) {
+				oldest, old = k, v
+			}
+		}
+		if e.Timestamp.Before(old.Timestamp) {
+			return
+		}
+		delete(r.v, oldest)
+	}
+	r.v[b] = e
+}
+
+// list return exemplars ordered by timestamp
+func (r *exemplarBuckets) list() []*Exemplar {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]*Exemplar, 0, len(r.v))
+	for _, e := range r.v {
+		res = append(res, e)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp.Before(res[j].Timestamp)
+	})
+	return res
+}
+
+// latestByRange return the most recent exemplar of each quantile range,
+// "median" is up to median, "95percentile" is up to p95 and "max" is others
+func (r *exemplarBuckets) latestByRange(median, p95 float64) map[string]Data {
+	res := make(map[string]Data)
+	for _, e := range r.list() {
+		switch {
+		case e.Value <= median:
+			res["median"] = e
+		case e.Value <= p95:
+			res["95percentile"] = e
+		default:
+			res["max"] = e
+		}
+	}
+	return res
+}
+
+// AddWithExemplar add count for CounterMetrics with Exemplar.
+// exemplar is dropped when the length of labels is over 128 characters
+func (c *SimpleCollector) AddWithExemplar(key string, delta float64, e Exemplar) {
+	c.mu.Lock()
+	ex := newExemplar(e, delta, c.clock.Now())
+	key, err := c.add(key, delta, ex)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeCounter, Value: delta, Exemplar: ex})
+	}
+}
+
+// HistogramWithExemplar add metrics for Histogram with Exemplar.
+// exemplar is dropped when the length of labels is over 128 characters
+func (c *SimpleCollector) HistogramWithExemplar(key string, delta float64, e Exemplar) {
+	c.mu.Lock()
+	ex := newExemplar(e, delta, c.clock.Now())
+	key, err := c.histogram(key, delta, ex)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeHistogram, Value: delta, Exemplar: ex})
+	}
+}
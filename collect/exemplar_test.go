@@ -0,0 +1,126 @@
+package collect
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExemplarMarshalJSON(t *testing.T) {
+	e := &Exemplar{
+		TraceID:   "abc",
+		Labels:    map[string]string{"route": "/a", "method": "GET"},
+		Timestamp: time.Unix(1500000000, 500000000),
+		Value:     0.25,
+	}
+	expect := []byte(`{"labels":{"method":"GET","route":"/a","trace_id":"abc"},"timestamp":1500000000.5,"value":0.25}`)
+	got, err := e.MarshalJSON()
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestAddWithExemplar(t *testing.T) {
+	c := NewSimpleCollector()
+	ts := time.Unix(1500000000, 0)
+	c.AddWithExemplar("c", 1, Exemplar{TraceID: "a", Timestamp: ts})
+	c.AddWithExemplar("c", 2, Exemplar{TraceID: "b", Timestamp: ts})
+	// expect: dropped too long labels
+	c.AddWithExemplar("c", 3, Exemplar{TraceID: strings.Repeat("x", maxExemplarLabelsLength)})
+	c.Add("c", 4)
+
+	expect := []byte(`{"c":10,"c.exemplar":{"labels":{"trace_id":"b"},"timestamp":1500000000,"value":2}}`)
+	got, err := c.GetMetrics("c")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestHistogramWithExemplar(t *testing.T) {
+	c := NewSimpleCollector()
+	ts := time.Unix(1500000000, 0)
+	for i := 1; i <= 20; i++ {
+		c.Histogram("h", float64(i))
+	}
+	c.HistogramWithExemplar("h", 1, Exemplar{TraceID: "low1", Timestamp: ts})
+	c.HistogramWithExemplar("h", 2, Exemplar{TraceID: "low2", Timestamp: ts.Add(time.Second)})
+	c.HistogramWithExemplar("h", 15, Exemplar{TraceID: "mid", Timestamp: ts})
+	c.HistogramWithExemplar("h", 100, Exemplar{TraceID: "high", Timestamp: ts})
+
+	agg := c.metrics["h"].Aggregate()
+	cases := []struct {
+		key     string
+		traceID string
+	}{
+		{"h.median.exemplar", "low2"},
+		{"h.95percentile.exemplar", "mid"},
+		{"h.max.exemplar", "high"},
+	}
+	for i, tc := range cases {
+		e, ok := agg[tc.key].(*Exemplar)
+		if !ok {
+			t.Fatalf("#%d: want exemplar %s, got %v", i, tc.key, agg[tc.key])
+		}
+		if e.TraceID != tc.traceID {
+			t.Errorf("#%d: want trace id %s, got %s", i, tc.traceID, e.TraceID)
+		}
+	}
+}
+
+func TestHistogramTailExemplar(t *testing.T) {
+	c := NewSimpleCollector()
+	for i := 0; i < 100; i++ {
+		c.Histogram("lat", 10)
+	}
+	c.HistogramWithExemplar("lat", 5000, Exemplar{TraceID: "spike"})
+	for i := 0; i < 10*maxExemplars; i++ {
+		c.HistogramWithExemplar("lat", 10, Exemplar{TraceID: fmt.Sprintf("ok%d", i)})
+	}
+
+	// expect: the tail exemplar is kept after many low values
+	agg := c.metrics["lat"].Aggregate()
+	e, ok := agg["lat.max.exemplar"].(*Exemplar)
+	if !ok {
+		t.Fatalf("want exemplar, got %v", agg["lat.max.exemplar"])
+	}
+	if e.TraceID != "spike" {
+		t.Errorf("want trace id %s, got %s", "spike", e.TraceID)
+	}
+	expect := fmt.Sprintf("ok%d", 10*maxExemplars-1)
+	if e := agg["lat.median.exemplar"].(*Exemplar); e.TraceID != expect {
+		t.Errorf("want trace id %s, got %s", expect, e.TraceID)
+	}
+}
+
+func TestExemplarBuckets(t *testing.T) {
+	var r exemplarBuckets
+	ts := time.Unix(0, 0)
+	for i := 0; i < maxExemplars+10; i++ {
+		r.add(&Exemplar{Value: math.Ldexp(1, i), Timestamp: ts.Add(time.Duration(i) * time.Second)})
+	}
+	list := r.list()
+	if len(list) != maxExemplars {
+		t.Fatalf("want size %d, got %d", maxExemplars, len(list))
+	}
+	if first := list[0].Value; first != math.Ldexp(1, 10) {
+		t.Errorf("want oldest value %v, got %v", math.Ldexp(1, 10), first)
+	}
+
+	// the latest exemplar of the same bucket
+	r.add(&Exemplar{Value: 1 << 20, Timestamp: ts})
+	r.add(&Exemplar{Value: 1<<20 + 1, Timestamp: ts.Add(time.Hour)})
+	for _, e := range r.list() {
+		if exemplarBucket(e.Value) == exemplarBucket(1<<20) && e.Value != 1<<20+1 {
+			t.Errorf("want value %v, got %v", 1<<20+1, e.Value)
+		}
+	}
+}
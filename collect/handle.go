@@ -0,0 +1,126 @@
+package collect
+
+// Handles are bound to the metrics of the key when they are created, and record values
+// without the map lookup and the lock of the collector. the key is not resolved again,
+// so recorded values are lost after Delete or LoadState of the key.
+// handles of invalid keys or other metric types do nothing, the same as ignored calls of the collector
+
+// Counter is a handle of CounterMetrics
+type Counter struct {
+	c *SimpleCollector
+	m *CounterMetrics
+}
+
+// Counter return a handle of CounterMetrics, add the key when not exist
+func (c *SimpleCollector) Counter(key string) *Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.counterMetrics(key)
+	if err != nil {
+		return &Counter{}
+	}
+	return &Counter{c: c, m: m}
+}
+
+// Inc add 1 to the counter
+func (h *Counter) Inc() {
+	h.Add(1)
+}
+
+// Add add count to the counter
+func (h *Counter) Add(delta float64) {
+	if h.m == nil {
+		return
+	}
+	h.m.add(delta, nil)
+	h.c.notify(Event{Key: h.m.key, Type: TypeCounter, Value: delta})
+}
+
+// Gauge is a handle of GaugeMetrics
+type Gauge struct {
+	c     *SimpleCollector
+	m     *GaugeMetrics
+	clock Clock
+}
+
+// NewGauge return a handle of GaugeMetrics, add the key when not exist.
+// the handle uses Clock of the collector at the time
+func (c *SimpleCollector) NewGauge(key string) *Gauge {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.gaugeMetrics(key)
+	if err != nil {
+		return &Gauge{}
+	}
+	return &Gauge{c: c, m: m, clock: c.clock}
+}
+
+// Set set the value of the gauge
+func (h *Gauge) Set(v float64) {
+	if h.m == nil {
+		return
+	}
+	h.m.set(v, h.clock.Now())
+	h.c.notify(Event{Key: h.m.key, Type: TypeGauge, Value: v})
+}
+
+// Histogram is a handle of HistogramMetrics
+type Histogram struct {
+	c *SimpleCollector
+	m *HistogramMetrics
+}
+
+// NewHistogram return a handle of HistogramMetrics, add the key when not exist
+func (c *SimpleCollector) NewHistogram(key string) *Histogram {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.histogramMetrics(key)
+	if err != nil {
+		return &Histogram{}
+	}
+	return &Histogram{c: c, m: m}
+}
+
+// Observe add the value to the histogram
+func (h *Histogram) Observe(v float64) {
+	if h.m == nil {
+		return
+	}
+	h.m.value.Update(v)
+	h.c.notify(Event{Key: h.m.key, Type: TypeHistogram, Value: v})
+}
+
+// ObserveN add the value observed n times to the histogram
+func (h *Histogram) ObserveN(v float64, n int64) {
+	if h.m == nil || n <= 0 {
+		return
+	}
+	updateN(h.m.value, v, n)
+	h.c.notify(Event{Key: h.m.key, Type: TypeHistogram, Value: v, Count: n})
+}
+
+// Set is a handle of SetMetrics
+type Set struct {
+	c *SimpleCollector
+	m *SetMetrics
+}
+
+// NewSet return a handle of SetMetrics, add the key when not exist
+func (c *SimpleCollector) NewSet(key string) *Set {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.setMetrics(key)
+	if err != nil {
+		return &Set{}
+	}
+	return &Set{c: c, m: m}
+}
+
+// Add add the value to the set
+func (h *Set) Add(v string) {
+	if h.m == nil {
+		return
+	}
+	h.m.value.set(v)
+	h.c.notify(Event{Key: h.m.key, Type: TypeSet, Values: []string{v}})
+}
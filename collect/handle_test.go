@@ -0,0 +1,120 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHandles(t *testing.T) {
+	c := NewSimpleCollector()
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	c.SetClock(clock)
+	var events []Event
+	c.AddObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	counter := c.Counter("c")
+	counter.Inc()
+	counter.Add(2)
+	c.Add("c", 1)
+	gauge := c.NewGauge("g")
+	gauge.Set(1.5)
+	histogram := c.NewHistogram("h")
+	histogram.Observe(1)
+	histogram.Observe(3)
+	set := c.NewSet("s")
+	set.Add("a")
+	set.Add("b")
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"c", []byte(`{"c":4}`)},
+		{"g", []byte(`{"g":1.5}`)},
+		{"h", []byte(`{"h.95percentile":2.9,"h.avg":2,"h.count":2,"h.max":3,"h.median":3}`)},
+		{"s", []byte(`{"s":["a","b"]}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+	if got := c.metrics["g"].(*GaugeMetrics).updated; !got.Equal(clock.Now()) {
+		t.Errorf("want updated %v, got %v", clock.Now(), got)
+	}
+	if len(events) != 8 {
+		t.Errorf("want 8 events, got %d", len(events))
+	}
+
+	// the handle of the same key share the metrics
+	c.Counter("c").Inc()
+	expect := []byte(`{"c":5}`)
+	if got, _ := c.GetMetrics("c"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestHandlesIgnored(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Gauge("g", 1)
+	c.SetKeyPolicy(PrometheusKeyPolicy(KeyModeReject))
+
+	// other metric type and invalid key
+	c.Counter("g").Inc()
+	c.NewHistogram("g").Observe(1)
+	c.NewSet("g").Add("a")
+	c.NewGauge("bad key").Set(2)
+
+	if got, expect := c.GetMetricsKeys(), []string{"g"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	expect := []byte(`{"g":1}`)
+	if got, _ := c.GetMetrics("g"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	c := NewSimpleCollector()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add("requests", 1)
+	}
+}
+
+func BenchmarkCounterHandle(b *testing.B) {
+	c := NewSimpleCollector()
+	counter := c.Counter("requests")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.Inc()
+	}
+}
+
+func BenchmarkCounterAddParallel(b *testing.B) {
+	c := NewSimpleCollector()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add("requests", 1)
+		}
+	})
+}
+
+func BenchmarkCounterHandleParallel(b *testing.B) {
+	c := NewSimpleCollector()
+	counter := c.Counter("requests")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			counter.Inc()
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package collect
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// about sample errors
+var (
+	ErrInvalidSample = errors.New("invalid sample")
+)
+
+// HDRSample is implemented WeightedSample.
+// it is a High Dynamic Range histogram, counts values in log-linear buckets with fixed memory.
+// values are rounded to multiples of lowest, and kept within relative error of significant digits.
+// values less than 0 are counted as 0 and values greater than highest are counted as highest.
+// see http://hdrhistogram.org/
+type HDRSample struct {
+	lowest  float64
+	highest float64
+	digits  int
+	// scale is 1/lowest, values are divided by it to avoid errors of decimal lowest
+	scale float64
+
+	highestUnit                 int64
+	subBucketHalfCountMagnitude uint
+	subBucketCount              int64
+	subBucketHalfCount          int64
+	subBucketMask               int64
+
+	counts []int64
+	total  int64
+	mu     sync.RWMutex
+}
+
+// NewHDRSample return new HDRSample tracks values between lowest and highest with significant digits 1 to 5,
+// e.g. lowest 0.001, highest 3600000 and digits 3 tracks milliseconds from 1 microsecond to 1 hour within 0.1%.
+// return ErrInvalidSample when the range or digits are invalid
+func NewHDRSample(lowest, highest float64, digits int) (*HDRSample, error) {
+	if !(lowest > 0) || math.IsInf(lowest, 0) {
+		return nil, errors.Wrapf(ErrInvalidSample, "lowest %v", lowest)
+	}
+	if digits < 1 || 5 < digits {
+		return nil, errors.Wrapf(ErrInvalidSample, "digits %d", digits)
+	}
+	units := math.Floor(highest / lowest)
+	if !(units >= 2) || units > math.MaxInt64/4 {
+		return nil, errors.Wrapf(ErrInvalidSample, "highest %v", highest)
+	}
+	s := &HDRSample{
+		lowest:      lowest,
+		highest:     highest,
+		digits:      digits,
+		scale:       1 / lowest,
+		highestUnit: int64(units),
+	}
+
+	// values up to 2*10^digits are counted with single unit resolution
+	largest := 2 * math.Pow10(digits)
+	magnitude := uint(math.Ceil(math.Log2(largest)))
+	s.subBucketHalfCountMagnitude = magnitude - 1
+	s.subBucketCount = 1 << magnitude
+	s.subBucketHalfCount = s.subBucketCount / 2
+	s.subBucketMask = s.subBucketCount - 1
+
+	buckets := 1
+	for smallest := s.subBucketCount; smallest <= s.highestUnit; smallest <<= 1 {
+		buckets++
+	}
+	s.counts = make([]int64, (buckets+1)*int(s.subBucketHalfCount))
+	return s, nil
+}
+
+// Update add a value
+func (s *HDRSample) Update(v float64) {
+	s.UpdateN(v, 1)
+}
+
+// UpdateN add a value observed n times, NaN is ignored
+func (s *HDRSample) UpdateN(v float64, n int64) {
+	if n <= 0 || math.IsNaN(v) {
+		return
+	}
+	i := s.countsIndex(s.unit(v))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[i] += n
+	s.total += n
+}
+
+// Values return sorted representative values of counted buckets, each bucket is included once
+func (s *HDRSample) Values() []float64 {
+	values, _ := s.WeightedValues()
+	return values
+}
+
+// WeightedValues return sorted representative values of counted buckets and their counts
+func (s *HDRSample) WeightedValues() ([]float64, []int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make([]float64, 0)
+	weights := make([]int64, 0)
+	for i, n := range s.counts {
+		if n == 0 {
+			continue
+		}
+		values = append(values, s.value(i))
+		weights = append(weights, n)
+	}
+	return values, weights
+}
+
+// Count return number of all updated values
+func (s *HDRSample) Count() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.total
+}
+
+// empty return empty HDRSample with the same range and digits
+func (s *HDRSample) empty() *HDRSample {
+	return &HDRSample{
+		lowest:                      s.lowest,
+		highest:                     s.highest,
+		digits:                      s.digits,
+		scale:                       s.scale,
+		highestUnit:                 s.highestUnit,
+		subBucketHalfCountMagnitude: s.subBucketHalfCountMagnitude,
+		subBucketCount:              s.subBucketCount,
+		subBucketHalfCount:          s.subBucketHalfCount,
+		subBucketMask:               s.subBucketMask,
+		counts:                      make([]int64, len(s.counts)),
+	}
+}
+
+// sameLayout return whether other counts values in the same buckets
+func (s *HDRSample) sameLayout(other *HDRSample) bool {
+	return s.lowest == other.lowest && s.highestUnit == other.highestUnit && s.digits == other.digits
+}
+
+// unit return a value in multiples of lowest, clamped to [0, highest]
+func (s *HDRSample) unit(v float64) int64 {
+	u := math.Floor(v*s.scale + 0.5)
+	if u < 0 {
+		return 0
+	}
+	if u > float64(s.highestUnit) {
+		return s.highestUnit
+	}
+	return int64(u)
+}
+
+// countsIndex return index of counts for the unit value
+func (s *HDRSample) countsIndex(u int64) int {
+	bucket := s.bucketIndex(u)
+	sub := u >> uint(bucket)
+	return (bucket+1)<<s.subBucketHalfCountMagnitude + int(sub-s.subBucketHalfCount)
+}
+
+// bucketIndex return index of power of 2 bucket for the unit value
+func (s *HDRSample) bucketIndex(u int64) int {
+	// smallest power of 2 containing u
+	pow2 := 64 - bits.LeadingZeros64(uint64(u|s.subBucketMask))
+	return pow2 - int(s.subBucketHalfCountMagnitude+1)
+}
+
+// value return representative value of the counts index, it is the middle of the bucket
+func (s *HDRSample) value(i int) float64 {
+	bucket := i>>s.subBucketHalfCountMagnitude - 1
+	sub := int64(i)&(s.subBucketHalfCount-1) + s.subBucketHalfCount
+	if bucket < 0 {
+		sub -= s.subBucketHalfCount
+		bucket = 0
+	}
+	lowest := sub << uint(bucket)
+	size := int64(1) << uint(bucket)
+	return float64(lowest+size>>1) / s.scale
+}
+
+// RegisterHDRHistogram add HistogramMetrics using HDRSample of the range and digits
+func (c *SimpleCollector) RegisterHDRHistogram(key string, lowest, highest float64, digits int) error {
+	s, err := NewHDRSample(lowest, highest, digits)
+	if err != nil {
+		return err
+	}
+	return c.RegisterHistogram(key, s)
+}
@@ -0,0 +1,183 @@
+package collect
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewHDRSampleError(t *testing.T) {
+	cases := []struct {
+		lowest  float64
+		highest float64
+		digits  int
+	}{
+		{0, 1000, 3},
+		{-1, 1000, 3},
+		{math.NaN(), 1000, 3},
+		{1, 1, 3},
+		{1, math.Inf(1), 3},
+		{1, 1000, 0},
+		{1, 1000, 6},
+	}
+	for i, tc := range cases {
+		if _, err := NewHDRSample(tc.lowest, tc.highest, tc.digits); errors.Cause(err) != ErrInvalidSample {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidSample, err)
+		}
+	}
+}
+
+func TestHDRSampleExactValues(t *testing.T) {
+	// values within 2*10^digits units are counted exactly
+	hdr := NewSimpleCollector()
+	if err := hdr.RegisterHDRHistogram("h", 1, 3600000, 3); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := hdr.RegisterHDRHistogram("h", 1, 3600000, 3); err != ErrAlreadyExistMetrics {
+		t.Fatalf("want error %v, got %v", ErrAlreadyExistMetrics, err)
+	}
+	slice := NewSimpleCollector()
+	for _, v := range []float64{1, 3, 3, 1999, 0} {
+		hdr.Histogram("h", v)
+		slice.Histogram("h", v)
+	}
+	got, err := hdr.GetMetrics("h")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect, _ := slice.GetMetrics("h")
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestHDRSampleRelativeError(t *testing.T) {
+	// milliseconds from 1 microsecond to 1 hour
+	s, err := NewHDRSample(0.001, 3600000, 3)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	size := len(s.counts)
+	var values sortedFloats
+	for v := 0.001; v < 3600000; v *= 1.01 {
+		s.Update(v)
+		values = append(values, v)
+	}
+	if got := len(s.counts); got != size {
+		t.Errorf("want fixed counts %d, got %d", size, got)
+	}
+	if got, expect := s.Count(), int64(len(values)); got != expect {
+		t.Errorf("want count %d, got %d", expect, got)
+	}
+
+	list := newWeightedFloats(s.WeightedValues())
+	cases := []struct {
+		name   string
+		expect float64
+		got    float64
+	}{
+		{"avg", values.average(), list.average()},
+		{"max", values.max(), list.max()},
+		{"median", values.median(), list.median()},
+		{"95percentile", values.percentile(0.95), list.percentile(0.95)},
+	}
+	for i, tc := range cases {
+		if math.Abs(tc.got-tc.expect) > tc.expect*0.001 {
+			t.Errorf("#%d: want %s %v within 0.1%%, got %v", i, tc.name, tc.expect, tc.got)
+		}
+	}
+}
+
+func TestHDRSampleClamp(t *testing.T) {
+	s, err := NewHDRSample(1, 1000, 2)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	s.Update(-5)
+	s.Update(math.NaN())
+	s.Update(1e9)
+	s.UpdateN(10, 3)
+
+	values, weights := s.WeightedValues()
+	if expect := []float64{0, 10}; !reflect.DeepEqual(values[:2], expect) {
+		t.Errorf("want %v, got %v", expect, values[:2])
+	}
+	if expect := []int64{1, 3, 1}; !reflect.DeepEqual(weights, expect) {
+		t.Errorf("want %v, got %v", expect, weights)
+	}
+	if max := values[len(values)-1]; math.Abs(max-1000) > 1000*0.01 {
+		t.Errorf("want max %v within 1%%, got %v", 1000, max)
+	}
+}
+
+func TestHDRSampleMergeAndState(t *testing.T) {
+	src := NewSimpleCollector()
+	if err := src.RegisterHDRHistogram("h", 0.001, 60000, 3); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	dst := NewSimpleCollector()
+	if err := dst.RegisterHDRHistogram("h", 0.001, 60000, 3); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	all := NewSimpleCollector()
+	if err := all.RegisterHDRHistogram("h", 0.001, 60000, 3); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	for i, v := range []float64{0.25, 12.5, 830, 45000, 3.125} {
+		if i%2 == 0 {
+			src.Histogram("h", v)
+		} else {
+			dst.Histogram("h", v)
+		}
+		all.Histogram("h", v)
+	}
+
+	// expect: counts are added, and a new key keeps HDRSample
+	empty := NewSimpleCollector()
+	for i, c := range []*SimpleCollector{dst, empty} {
+		if err := c.Merge(src); err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+	}
+	if _, ok := empty.metrics["h"].(*HistogramMetrics).value.(*HDRSample); !ok {
+		t.Errorf("want HDRSample, got %T", empty.metrics["h"].(*HistogramMetrics).value)
+	}
+	expect, _ := all.GetMetrics("h")
+	if got, _ := dst.GetMetrics("h"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+
+	var buf bytes.Buffer
+	if err := dst.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	restored := NewSimpleCollector()
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got, _ := restored.GetMetrics("h"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+	got := restored.metrics["h"].(*HistogramMetrics).value.(*HDRSample)
+	want := dst.metrics["h"].(*HistogramMetrics).value.(*HDRSample)
+	if !reflect.DeepEqual(got.counts, want.counts) {
+		t.Errorf("want same counts after LoadState")
+	}
+
+	// reset clear counts
+	dst.Reset("h")
+	if got := want.Count(); got != 0 {
+		t.Errorf("want count 0, got %d", got)
+	}
+}
+
+func BenchmarkHDRSampleUpdate(b *testing.B) {
+	s, _ := NewHDRSample(0.001, 3600000, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Update(float64(i%100000) * 0.01)
+	}
+}
@@ -0,0 +1,264 @@
+package collect
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// about state set errors
+var (
+	ErrUnknownState   = errors.New("unknown state")
+	ErrDuplicateState = errors.New("duplicate state")
+)
+
+// InfoMetrics is implemented Metrics for Info
+type InfoMetrics struct {
+	key     string
+	labels  map[string]string
+	updated time.Time
+	mu      sync.RWMutex
+}
+
+// Aggregate return key and labels
+func (m *InfoMetrics) Aggregate() map[string]Data {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v := make(map[string]string, len(m.labels))
+	for k, l := range m.labels {
+		v[k] = l
+	}
+	return map[string]Data{
+		m.key: &StringMap{v: v},
+	}
+}
+
+// GetType return MetricType
+func (m *InfoMetrics) GetType() MetricType {
+	return TypeInfo
+}
+
+// Merge take labels of other InfoMetrics when it is updated later
+func (m *InfoMetrics) Merge(other Metrics) error {
+	o, ok := other.(*InfoMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	o.mu.RLock()
+	labels, updated := o.labels, o.updated
+	o.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if updated.After(m.updated) {
+		m.labels = labels
+		m.updated = updated
+	}
+	return nil
+}
+
+// Reset remove all labels
+func (m *InfoMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labels = make(map[string]string)
+	m.updated = time.Time{}
+}
+
+// StateSetMetrics is implemented Metrics for StateSet
+type StateSetMetrics struct {
+	key     string
+	states  []string
+	active  int
+	updated time.Time
+	mu      sync.RWMutex
+}
+
+// Aggregate return key and states, the active state is 1 and others are 0
+func (m *StateSetMetrics) Aggregate() map[string]Data {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v := make(map[string]float64, len(m.states))
+	for i, s := range m.states {
+		if i == m.active {
+			v[s] = 1
+		} else {
+			v[s] = 0
+		}
+	}
+	return map[string]Data{
+		m.key: &FloatMap{v: v},
+	}
+}
+
+// GetType return MetricType
+func (m *StateSetMetrics) GetType() MetricType {
+	return TypeStateSet
+}
+
+// Merge take states of other StateSetMetrics when it is updated later or m has no states
+func (m *StateSetMetrics) Merge(other Metrics) error {
+	o, ok := other.(*StateSetMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	o.mu.RLock()
+	states, active, updated := o.states, o.active, o.updated
+	o.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if updated.After(m.updated) || len(m.states) == 0 {
+		m.states = states
+		m.active = active
+		m.updated = updated
+	}
+	return nil
+}
+
+// Reset set the first state to active
+func (m *StateSetMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = 0
+	m.updated = time.Time{}
+}
+
+// StringMap is implemented Data
+type StringMap struct {
+	v map[string]string
+}
+
+// MarshalJSON return specific encoded json sorted by keys
+func (m *StringMap) MarshalJSON() ([]byte, error) {
+	keys := make([]string, 0, len(m.v))
+	for k := range m.v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(quoteJSON(k))
+		buf.WriteByte(':')
+		buf.Write(quoteJSON(m.v[k]))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Info set constant labels for InfoMetrics, e.g. version and revision of the build
+func (c *SimpleCollector) Info(key string, labels map[string]string) {
+	if key, err := c.info(key, labels); err == nil {
+		c.notify(Event{Key: key, Type: TypeInfo, Labels: labels})
+	}
+}
+
+func (c *SimpleCollector) info(key string, labels map[string]string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return "", ErrInvalidKey
+	}
+
+	// add key
+	if _, dup := c.metrics[key]; !dup {
+		c.metrics[key] = &InfoMetrics{
+			key: key,
+		}
+	}
+
+	// set labels, ignore otherwise
+	v, ok := c.metrics[key].(*InfoMetrics)
+	if !ok {
+		return "", ErrMismatchMetricType
+	}
+	copied := make(map[string]string, len(labels))
+	for k, l := range labels {
+		copied[k] = l
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.labels = copied
+	v.updated = c.clock.Now()
+	return key, nil
+}
+
+// RegisterStateSet add StateSetMetrics with enumerated states, the first state is active.
+// return ErrDuplicateState when the states are duplicated
+func (c *SimpleCollector) RegisterStateSet(key string, states ...string) error {
+	if len(states) == 0 {
+		return ErrUnknownState
+	}
+	seen := make(map[string]struct{}, len(states))
+	for _, s := range states {
+		if _, dup := seen[s]; dup {
+			return errors.Wrapf(ErrDuplicateState, "state %q", s)
+		}
+		seen[s] = struct{}{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+
+	if _, dup := c.metrics[key]; dup {
+		return ErrAlreadyExistMetrics
+	}
+	s := make([]string, len(states))
+	copy(s, states)
+	c.metrics[key] = &StateSetMetrics{
+		key:     key,
+		states:  s,
+		updated: c.clock.Now(),
+	}
+	return nil
+}
+
+// SetState change the active state of StateSetMetrics registered by RegisterStateSet
+func (c *SimpleCollector) SetState(key string, state string) error {
+	key, err := c.setState(key, state)
+	if err != nil {
+		return err
+	}
+	c.notify(Event{Key: key, Type: TypeStateSet, Values: []string{state}})
+	return nil
+}
+
+func (c *SimpleCollector) setState(key string, state string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return "", ErrInvalidKey
+	}
+
+	m, ok := c.metrics[key]
+	if !ok {
+		return "", ErrNotFoundMetrics
+	}
+	v, ok := m.(*StateSetMetrics)
+	if !ok {
+		return "", ErrMismatchMetricType
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, s := range v.states {
+		if s == state {
+			v.active = i
+			v.updated = c.clock.Now()
+			return key, nil
+		}
+	}
+	return "", ErrUnknownState
+}
@@ -0,0 +1,93 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestInfo(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Info("build", map[string]string{"version": "1.0.0", "revision": "abc"})
+	if got := c.metrics["build"].GetType(); got != TypeInfo {
+		t.Fatalf("want type %s, got %s", TypeInfo, got)
+	}
+	expect := []byte(`{"build":{"revision":"abc","version":"1.0.0"}}`)
+	got, err := c.GetMetrics("build")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+
+	// expect: labels are replaced
+	c.Info("build", map[string]string{"version": "1.0.1"})
+	expect = []byte(`{"build":{"version":"1.0.1"}}`)
+	got, err = c.GetMetrics("build")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestStateSet(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Add("counter", 1)
+	if err := c.RegisterStateSet("breaker", "closed", "half_open", "open"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	cases := []struct {
+		key       string
+		state     string
+		expectErr error
+		expect    []byte
+	}{
+		{"breaker", "", ErrUnknownState, []byte(`{"breaker":{"closed":1,"half_open":0,"open":0}}`)},
+		{"breaker", "open", nil, []byte(`{"breaker":{"closed":0,"half_open":0,"open":1}}`)},
+		{"breaker", "broken", ErrUnknownState, []byte(`{"breaker":{"closed":0,"half_open":0,"open":1}}`)},
+		{"unknown", "open", ErrNotFoundMetrics, nil},
+		{"counter", "open", ErrMismatchMetricType, []byte(`{"counter":1}`)},
+	}
+	for i, tc := range cases {
+		if err := c.SetState(tc.key, tc.state); err != tc.expectErr {
+			t.Fatalf("#%d: want error %v, got %v", i, tc.expectErr, err)
+		}
+		if tc.expect == nil {
+			continue
+		}
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+
+	if err := c.RegisterStateSet("empty"); err != ErrUnknownState {
+		t.Errorf("want error %v, got %v", ErrUnknownState, err)
+	}
+	if err := c.RegisterStateSet("dup", "a", "b", "a"); errors.Cause(err) != ErrDuplicateState {
+		t.Errorf("want error %v, got %v", ErrDuplicateState, err)
+	}
+}
+
+func TestMergeRegisteredStateSet(t *testing.T) {
+	src := NewSimpleCollector()
+	if err := src.RegisterStateSet("breaker", "closed", "open"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	dst := NewSimpleCollector()
+	if err := dst.Merge(src); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := []byte(`{"breaker":{"closed":1,"open":0}}`)
+	if got, _ := dst.GetMetrics("breaker"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
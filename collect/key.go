@@ -0,0 +1,218 @@
+package collect
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// about key errors
+var (
+	ErrInvalidKey = errors.New("invalid metrics key")
+)
+
+// maxRejectedKeys is maximum number of kept rejected keys
+const maxRejectedKeys = 1000
+
+// KeyPolicy is a naming policy of metrics keys
+type KeyPolicy interface {
+	// Normalize return a key to use, or error when the key is rejected
+	Normalize(key string) (string, error)
+}
+
+// KeyMode is a mode of handling invalid keys
+type KeyMode int
+
+// Enum of KeyMode
+const (
+	// KeyModeAllow use invalid keys as it is
+	KeyModeAllow KeyMode = iota
+	// KeyModeReject drop metrics of invalid keys
+	KeyModeReject
+	// KeyModeSanitize replace invalid characters of keys
+	KeyModeSanitize
+)
+
+func (m KeyMode) String() string {
+	switch m {
+	case KeyModeAllow:
+		return "allow"
+	case KeyModeReject:
+		return "reject"
+	case KeyModeSanitize:
+		return "sanitize"
+	default:
+		return "not supported key mode"
+	}
+}
+
+// CharsetPolicy is implemented KeyPolicy, validate each characters of keys
+type CharsetPolicy struct {
+	Mode KeyMode
+	// Valid reports whether r is allowed at the i-th character of the key
+	Valid func(i int, r rune) bool
+	// Replacement is used for invalid characters when KeyModeSanitize
+	Replacement rune
+}
+
+// Normalize return a key to use, or ErrInvalidKey when the key is rejected
+func (p *CharsetPolicy) Normalize(key string) (string, error) {
+	if p.Mode == KeyModeAllow {
+		return key, nil
+	}
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+
+	var (
+		buf     bytes.Buffer
+		invalid bool
+	)
+	for i, r := range []rune(key) {
+		if p.Valid(i, r) {
+			buf.WriteRune(r)
+			continue
+		}
+		invalid = true
+		buf.WriteRune(p.Replacement)
+	}
+	if !invalid {
+		return key, nil
+	}
+	if p.Mode == KeyModeReject {
+		return "", errors.Wrapf(ErrInvalidKey, "%q", key)
+	}
+	return buf.String(), nil
+}
+
+// PrometheusKeyPolicy return KeyPolicy for Prometheus metric names, [a-zA-Z_:][a-zA-Z0-9_:]*
+func PrometheusKeyPolicy(mode KeyMode) KeyPolicy {
+	return &CharsetPolicy{
+		Mode: mode,
+		Valid: func(i int, r rune) bool {
+			return isASCIILetter(r) || r == '_' || r == ':' || (i > 0 && isASCIIDigit(r))
+		},
+		Replacement: '_',
+	}
+}
+
+// StatsDKeyPolicy return KeyPolicy for StatsD buckets, exclude separators ":|@", "#" and spaces
+func StatsDKeyPolicy(mode KeyMode) KeyPolicy {
+	return &CharsetPolicy{
+		Mode: mode,
+		Valid: func(i int, r rune) bool {
+			return r < unicode.MaxASCII && unicode.IsPrint(r) && !unicode.IsSpace(r) && !strings.ContainsRune(":|@#", r)
+		},
+		Replacement: '_',
+	}
+}
+
+// GraphiteKeyPolicy return KeyPolicy for Graphite metric paths, [a-zA-Z0-9_.-]
+func GraphiteKeyPolicy(mode KeyMode) KeyPolicy {
+	return &CharsetPolicy{
+		Mode: mode,
+		Valid: func(i int, r rune) bool {
+			return isASCIILetter(r) || isASCIIDigit(r) || strings.ContainsRune("_.-", r)
+		},
+		Replacement: '_',
+	}
+}
+
+func isASCIILetter(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+}
+
+func isASCIIDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+// SetKeyPolicy setting KeyPolicy applied to recorded keys. nil means allow any keys
+func (c *SimpleCollector) SetKeyPolicy(p KeyPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keyPolicy = p
+}
+
+// RejectedKeys return sorted keys rejected by KeyPolicy
+func (c *SimpleCollector) RejectedKeys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]string, 0, len(c.rejectedKeys))
+	for k := range c.rejectedKeys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// normalizeKey return a key applied KeyPolicy, expect to be called with lock.
+// the policy is applied to the name part of keys with valid labels, and to the whole key otherwise
+func (c *SimpleCollector) normalizeKey(key string) (string, bool) {
+	if c.keyPolicy == nil {
+		return key, true
+	}
+	name, labels := splitLabeledKey(key)
+	if labels != "" && !validLabels(labels) {
+		name, labels = key, ""
+	}
+	normalized, err := c.keyPolicy.Normalize(name)
+	if err != nil {
+		if _, ok := c.rejectedKeys[key]; !ok && len(c.rejectedKeys) < maxRejectedKeys {
+			c.rejectedKeys[key] = struct{}{}
+		}
+		return "", false
+	}
+	return normalized + labels, true
+}
+
+// validLabels return whether labels is a label block in the format of LabeledKey,
+// e.g. `{code="200",method="GET"}`. label names are [a-zA-Z_][a-zA-Z0-9_]* and not duplicated,
+// and values are escaped by \\, \" and \n
+func validLabels(labels string) bool {
+	if len(labels) < 3 || labels[0] != '{' || labels[len(labels)-1] != '}' {
+		return false
+	}
+	s := labels[1 : len(labels)-1]
+	seen := make(map[string]struct{})
+	for {
+		i := strings.Index(s, `="`)
+		if i < 0 {
+			return false
+		}
+		name := s[:i]
+		if !validLabelName(name) {
+			return false
+		}
+		if _, dup := seen[name]; dup {
+			return false
+		}
+		seen[name] = struct{}{}
+
+		// value ends with unescaped quote
+		s = s[i+2:]
+		j := 0
+		for ; j < len(s) && s[j] != '"'; j++ {
+			if s[j] != '\\' {
+				continue
+			}
+			if j+1 >= len(s) || !strings.ContainsRune(`\"n`, rune(s[j+1])) {
+				return false
+			}
+			j++
+		}
+		if j >= len(s) {
+			return false
+		}
+		s = s[j+1:]
+		if s == "" {
+			return true
+		}
+		if s[0] != ',' {
+			return false
+		}
+		s = s[1:]
+	}
+}
@@ -0,0 +1,176 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	cases := []struct {
+		policy    KeyPolicy
+		key       string
+		expectKey string
+		expectErr error
+	}{
+		{PrometheusKeyPolicy(KeyModeReject), "http_requests:total", "http_requests:total", nil},
+		{PrometheusKeyPolicy(KeyModeReject), "http.requests", "", ErrInvalidKey},
+		{PrometheusKeyPolicy(KeyModeReject), "", "", ErrInvalidKey},
+		{PrometheusKeyPolicy(KeyModeSanitize), "1st request", "_st_request", nil},
+		{PrometheusKeyPolicy(KeyModeAllow), "http.requests", "http.requests", nil},
+		{StatsDKeyPolicy(KeyModeReject), "api.latency-ms", "api.latency-ms", nil},
+		{StatsDKeyPolicy(KeyModeReject), "api|latency", "", ErrInvalidKey},
+		{StatsDKeyPolicy(KeyModeSanitize), "a:b@c d\n", "a_b_c_d_", nil},
+		{GraphiteKeyPolicy(KeyModeReject), "servers.web-1.cpu_load", "servers.web-1.cpu_load", nil},
+		{GraphiteKeyPolicy(KeyModeSanitize), `servers."web 1"/cpu`, "servers._web_1__cpu", nil},
+	}
+	for i, c := range cases {
+		got, err := c.policy.Normalize(c.key)
+		if errors.Cause(err) != c.expectErr {
+			t.Fatalf("#%d: want error %v, got %v", i, c.expectErr, err)
+		}
+		if got != c.expectKey {
+			t.Errorf("#%d: want key %q, got %q", i, c.expectKey, got)
+		}
+	}
+}
+
+func TestCollectorKeyPolicy(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetKeyPolicy(GraphiteKeyPolicy(KeyModeReject))
+	c.Add("a b", 1)
+	c.Add("a.b", 1)
+	c.Gauge("g\n", 1)
+	c.Histogram(`"h"`, 1)
+	c.Set("s", "a")
+	if err := c.RegisterHistogram("h h", NewExpDecaySample(10, 0.015)); err != ErrInvalidKey {
+		t.Errorf("want error %v, got %v", ErrInvalidKey, err)
+	}
+
+	expectKeys := []string{"a.b", "s"}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expectKeys) {
+		t.Errorf("want keys %v, got %v", expectKeys, got)
+	}
+	expectRejected := []string{"\"h\"", "a b", "g\n", "h h"}
+	if got := c.RejectedKeys(); !reflect.DeepEqual(got, expectRejected) {
+		t.Errorf("want rejected keys %q, got %q", expectRejected, got)
+	}
+
+	// expect: sanitized keys are recorded
+	c.SetKeyPolicy(GraphiteKeyPolicy(KeyModeSanitize))
+	c.Add("a b", 1)
+	if _, err := c.GetMetrics("a_b"); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+}
+
+func TestGetMetricsEscapeKey(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Add("a\"b\n\x00", 1)
+	c.Set("s", "<\"\t\">")
+
+	for _, k := range c.GetMetricsKeys() {
+		b, err := c.GetMetrics(k)
+		if err != nil {
+			t.Fatalf("%q: want no error, got %v", k, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%q: want valid json, got %s", k, b)
+		}
+		if _, ok := got[k]; !ok {
+			t.Errorf("%q: want key in %v", k, got)
+		}
+	}
+}
+
+func TestKeyPolicyLabels(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetKeyPolicy(GraphiteKeyPolicy(KeyModeReject))
+	ctx := NewContext(context.Background(), c)
+	c.Add(`a{code="200",method="GET"}`, 1)
+	c.Add(`b{path="a\"b\\c\n"}`, 1)
+	AddCtx(WithLabels(ctx, map[string]string{"code": "500"}), "c", 1)
+
+	// invalid label blocks are checked as a part of the key
+	c.Add(`a{x y}`, 1)
+	c.Add(`a{}`, 1)
+	c.Add(`a{code="200"`, 1)
+	c.Add(`a{code=200}`, 1)
+	c.Add(`a{code="200",code="500"}`, 1)
+	c.Add(`a{code="200"method="GET"}`, 1)
+	c.Add(`a{code="\x"}`, 1)
+	c.Add(`a{code="2"00"}`, 1)
+	AddCtx(WithLabels(ctx, map[string]string{"bad name": "1"}), "d", 1)
+	AddCtx(WithLabels(ctx, map[string]string{"__name": "1"}), "e", 1)
+
+	expect := []string{`a{code="200",method="GET"}`, `b{path="a\"b\\c\n"}`, `c{code="500"}`}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want keys %q, got %q", expect, got)
+	}
+	if got := len(c.RejectedKeys()); got != 10 {
+		t.Errorf("want 10 rejected keys, got %q", c.RejectedKeys())
+	}
+}
+
+func TestKeyPolicyMergeAndLoadState(t *testing.T) {
+	src := NewSimpleCollector()
+	src.Add("a.b", 1)
+	src.Add("a b", 2)
+	src.Gauge("ok", 1)
+	var buf bytes.Buffer
+	if err := src.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	state := buf.String()
+
+	cases := []struct {
+		policy         KeyPolicy
+		load           bool
+		expectKeys     []string
+		expectRejected []string
+	}{
+		{GraphiteKeyPolicy(KeyModeReject), false, []string{"a.b", "ok"}, []string{"a b"}},
+		{GraphiteKeyPolicy(KeyModeReject), true, []string{"a.b", "ok"}, []string{"a b"}},
+		{PrometheusKeyPolicy(KeyModeSanitize), false, []string{"a_b", "ok"}, []string{}},
+		{PrometheusKeyPolicy(KeyModeSanitize), true, []string{"a_b", "ok"}, []string{}},
+	}
+	for i, tc := range cases {
+		c := NewSimpleCollector()
+		c.SetKeyPolicy(tc.policy)
+		var err error
+		if tc.load {
+			err = c.LoadState(strings.NewReader(state))
+		} else {
+			err = c.Merge(src)
+		}
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, tc.expectKeys) {
+			t.Errorf("#%d: want keys %v, got %v", i, tc.expectKeys, got)
+		}
+		if got := c.RejectedKeys(); !reflect.DeepEqual(got, tc.expectRejected) {
+			t.Errorf("#%d: want rejected keys %q, got %q", i, tc.expectRejected, got)
+		}
+	}
+
+	// sanitized keys are merged into the same key
+	c := NewSimpleCollector()
+	c.SetKeyPolicy(PrometheusKeyPolicy(KeyModeSanitize))
+	if err := c.Merge(src); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	got, err := c.GetMetrics("a_b")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"a_b":3}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
@@ -0,0 +1,294 @@
+package collect
+
+import (
+	"math"
+	"sort"
+)
+
+// Merge add count of other CounterMetrics
+func (m *CounterMetrics) Merge(other Metrics) error {
+	o, ok := other.(*CounterMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	o.value.mu.RLock()
+	v, e := o.value.f, o.exemplar
+	o.value.mu.RUnlock()
+
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.f += v
+	if e != nil && (m.exemplar == nil || e.Timestamp.After(m.exemplar.Timestamp)) {
+		m.exemplar = e
+	}
+	return nil
+}
+
+// Merge take the value of other GaugeMetrics when it is updated later
+func (m *GaugeMetrics) Merge(other Metrics) error {
+	o, ok := other.(*GaugeMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	v, updated := o.get()
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	if updated.After(m.updated) {
+		m.value.f = v
+		m.updated = updated
+	}
+	return nil
+}
+
+// Merge combine values of other HistogramMetrics
+func (m *HistogramMetrics) Merge(other Metrics) error {
+	o, ok := other.(*HistogramMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	for _, e := range o.exemplars.list() {
+		m.exemplars.add(e)
+	}
+	return m.value.Merge(o.value)
+}
+
+// Merge union values of other SetMetrics
+func (m *SetMetrics) Merge(other Metrics) error {
+	o, ok := other.(*SetMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	for _, v := range o.value.keys() {
+		m.value.set(v)
+	}
+	return nil
+}
+
+// Merge take values of other SnapshotMetrics when it is updated later
+func (m *SnapshotMetrics) Merge(other Metrics) error {
+	o, ok := other.(*SnapshotMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	o.value.mu.RLock()
+	values := make([]string, 0, len(o.value.v))
+	for k := range o.value.v {
+		values = append(values, k)
+	}
+	updated := o.updated
+	o.value.mu.RUnlock()
+
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	if updated.After(m.updated) {
+		m.value.v = make(map[string]struct{})
+		for _, v := range values {
+			m.value.v[v] = struct{}{}
+		}
+		m.updated = updated
+	}
+	return nil
+}
+
+// Merge append values of other Sample, weights of WeightedSample are kept
+func (s *FloatSlice) Merge(other Sample) error {
+	if o, ok := other.(WeightedSample); ok {
+		values, weights := o.WeightedValues()
+		for i, v := range values {
+			s.UpdateN(v, weights[i])
+		}
+		return nil
+	}
+	values := other.Values()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v = append(s.v, values...)
+	if s.w != nil {
+		for range values {
+			s.w = append(s.w, 1)
+		}
+	}
+	return nil
+}
+
+// Merge add counts of other HDRSample with the same range and digits, otherwise add values of other Sample
+func (s *HDRSample) Merge(other Sample) error {
+	if o, ok := other.(*HDRSample); ok && s.sameLayout(o) {
+		o.mu.RLock()
+		counts := make([]int64, len(o.counts))
+		copy(counts, o.counts)
+		total := o.total
+		o.mu.RUnlock()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, n := range counts {
+			s.counts[i] += n
+		}
+		s.total += total
+		return nil
+	}
+	if o, ok := other.(WeightedSample); ok {
+		values, weights := o.WeightedValues()
+		for i, v := range values {
+			s.UpdateN(v, weights[i])
+		}
+		return nil
+	}
+	for _, v := range other.Values() {
+		s.Update(v)
+	}
+	return nil
+}
+
+// Merge offer values of other Sample.
+// values of other ExpDecaySample keep their priorities, otherwise values are treated as updated now
+func (s *ExpDecaySample) Merge(other Sample) error {
+	o, ok := other.(*ExpDecaySample)
+	if !ok {
+		values := other.Values()
+		count := other.Count()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t := s.now()
+		for _, v := range values {
+			s.offer(v, s.priority(t))
+		}
+		s.count += count
+		return nil
+	}
+
+	o.mu.Lock()
+	items := make([]prioritized, len(*o.values))
+	copy(items, *o.values)
+	landmark := o.landmark
+	count := o.count
+	o.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// convert priorities to the own landmark
+	factor := math.Exp(s.alpha * landmark.Sub(s.landmark).Seconds())
+	for _, v := range items {
+		s.offer(v.value, v.priority*factor)
+	}
+	s.count += count
+	return nil
+}
+
+// Merge merge all metrics of other collector, other must be another *SimpleCollector,
+// otherwise return ErrInvalidCollector.
+// metrics of the same key are merged by Metrics.Merge, and other keys are copied.
+// keys are applied KeyPolicy of this collector, rejected keys are not merged.
+// return ErrMismatchMetricType without merging anything when types of any key are mismatched
+func (c *SimpleCollector) Merge(other Collector) error {
+	o, ok := other.(*SimpleCollector)
+	if !ok || o == c {
+		return ErrInvalidCollector
+	}
+
+	o.mu.RLock()
+	keys := make([]string, 0, len(o.metrics))
+	for k := range o.metrics {
+		keys = append(keys, k)
+	}
+	// sanitized keys may be the same, merge in order of keys
+	sort.Strings(keys)
+	metrics := make([]Metrics, len(keys))
+	for i, k := range keys {
+		metrics[i] = o.metrics[k]
+	}
+	o.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// check all keys before merging, not to leave a partial merge
+	types := make(map[string]MetricType, len(keys))
+	for i, k := range keys {
+		v := metrics[i]
+		k, ok := c.normalizeKey(k)
+		if !ok {
+			keys[i] = ""
+			continue
+		}
+		keys[i] = k
+		if m, dup := c.metrics[k]; dup {
+			types[k] = m.GetType()
+		}
+		if t, dup := types[k]; dup {
+			if t != v.GetType() {
+				return ErrMismatchMetricType
+			}
+			continue
+		}
+		if _, ok := v.(*DerivedMetrics); !ok && newEmptyMetrics(k, v) == nil {
+			return ErrMismatchMetricType
+		}
+		types[k] = v.GetType()
+	}
+	for i, k := range keys {
+		if k == "" {
+			continue
+		}
+		v := metrics[i]
+		if d, ok := v.(*DerivedMetrics); ok {
+			// copy the definition resolved by this collector
+			if _, dup := c.metrics[k]; !dup {
+				c.metrics[k] = newDerivedMetrics(k, d.expr, c)
+				continue
+			}
+		}
+		if _, dup := c.metrics[k]; !dup {
+			empty := newEmptyMetrics(k, v)
+			if empty == nil {
+				return ErrMismatchMetricType
+			}
+			if h, ok := empty.(*HistogramMetrics); ok {
+				setSampleClock(h.value, c.clock)
+			}
+			c.metrics[k] = empty
+		}
+		if err := c.metrics[k].Merge(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEmptyMetrics return empty Metrics of the same type as m
+func newEmptyMetrics(key string, m Metrics) Metrics {
+	switch m := m.(type) {
+	case *CounterMetrics:
+		return &CounterMetrics{key: key, value: &Float{}}
+	case *GaugeMetrics:
+		return &GaugeMetrics{key: key, value: &Float{}}
+	case *HistogramMetrics:
+		return &HistogramMetrics{key: key, value: newEmptySample(m.value)}
+	case *SetMetrics:
+		return &SetMetrics{key: key, value: &Map{v: make(map[string]struct{})}}
+	case *SnapshotMetrics:
+		return &SnapshotMetrics{key: key, value: &Map{v: make(map[string]struct{})}}
+	case *TopKMetrics:
+		return &TopKMetrics{key: key, value: NewSpaceSaving(m.value.k)}
+	case *TallyMetrics:
+		return &TallyMetrics{key: key, value: newCountMap(m.value.limit)}
+	case *InfoMetrics:
+		return &InfoMetrics{key: key}
+	case *StateSetMetrics:
+		return &StateSetMetrics{key: key}
+	default:
+		return nil
+	}
+}
+
+// newEmptySample return empty Sample with the same settings as s
+func newEmptySample(s Sample) Sample {
+	switch s := s.(type) {
+	case *ExpDecaySample:
+		return NewExpDecaySample(s.size, s.alpha)
+	case *HDRSample:
+		return s.empty()
+	default:
+		return &FloatSlice{v: make([]float64, 0)}
+	}
+}
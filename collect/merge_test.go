@@ -0,0 +1,136 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	c1 := NewSimpleCollector()
+	c1.Add("c", 1)
+	c1.Gauge("g", 1)
+	c1.Histogram("h", 1)
+	c1.Set("s", "a")
+	c1.Snapshot("ss", []string{"a"})
+
+	c2 := NewSimpleCollector()
+	c2.Add("c", 2)
+	c2.Add("c2", 2)
+	c2.Gauge("g", 2)
+	c2.Histogram("h", 2)
+	c2.Histogram("h", 3)
+	c2.Set("s", "b")
+	c2.Snapshot("ss", []string{"b"})
+
+	if err := c1.Merge(c2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"c", []byte(`{"c":3}`)},
+		{"c2", []byte(`{"c2":2}`)},
+		{"g", []byte(`{"g":2}`)},
+		{"h", []byte(`{"h.95percentile":2.9,"h.avg":2,"h.count":3,"h.max":3,"h.median":2}`)},
+		{"s", []byte(`{"s":["a","b"]}`)},
+		{"ss", []byte(`{"ss":["b"]}`)},
+	}
+	for i, c := range cases {
+		got, err := c1.GetMetrics(c.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("#%d: want %s, got %s", i, c.expect, got)
+		}
+	}
+
+	// expect: source collector is not changed
+	got, err := c2.GetMetrics("c")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"c":2}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	c1 := NewSimpleCollector()
+	c1.Add("a", 1)
+	c2 := NewSimpleCollector()
+	c2.Gauge("a", 1)
+
+	c2.Add("b", 1)
+	c2.Set("s", "x")
+
+	if err := c1.Merge(c2); err != ErrMismatchMetricType {
+		t.Errorf("want error %v, got %v", ErrMismatchMetricType, err)
+	}
+	// expect: nothing is merged
+	if got, expect := c1.GetMetricsKeys(), []string{"a"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	if err := c1.Merge(c1); err != ErrInvalidCollector {
+		t.Errorf("want error %v, got %v", ErrInvalidCollector, err)
+	}
+}
+
+func TestMergeGauge(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		dst, src      time.Time
+		expectValue   float64
+		expectUpdated time.Time
+	}{
+		{now, now.Add(time.Second), 2, now.Add(time.Second)},
+		{now, now.Add(-time.Second), 1, now},
+		{now, now, 1, now},
+	}
+	for i, c := range cases {
+		dst := &GaugeMetrics{key: "g", value: &Float{}}
+		dst.set(1, c.dst)
+		src := &GaugeMetrics{key: "g", value: &Float{}}
+		src.set(2, c.src)
+		if err := dst.Merge(src); err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		v, updated := dst.get()
+		if v != c.expectValue || !updated.Equal(c.expectUpdated) {
+			t.Errorf("#%d: want (%v, %v), got (%v, %v)", i, c.expectValue, c.expectUpdated, v, updated)
+		}
+	}
+}
+
+func TestMergeExpDecaySample(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	s1 := createFixedExpDecaySample(10, 0.015, clock)
+	s2 := createFixedExpDecaySample(10, 0.015, clock)
+	for i := 0; i < 8; i++ {
+		s1.Update(1)
+		s2.Update(2)
+	}
+
+	if err := s1.Merge(s2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got := s1.Count(); got != 16 {
+		t.Errorf("want count %d, got %d", 16, got)
+	}
+	if got := len(s1.Values()); got != 10 {
+		t.Errorf("want size %d, got %d", 10, got)
+	}
+
+	// merge other type of Sample
+	fs := &FloatSlice{}
+	fs.Update(3)
+	if err := s1.Merge(fs); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got := s1.Count(); got != 17 {
+		t.Errorf("want count %d, got %d", 17, got)
+	}
+}
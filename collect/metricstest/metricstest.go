@@ -0,0 +1,217 @@
+// Package metricstest implements utilities for testing code recording metrics
+package metricstest
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/takashabe/go-metrics/collect"
+)
+
+// Method names of Call
+const (
+	MethodAdd       = "Add"
+	MethodGauge     = "Gauge"
+	MethodHistogram = "Histogram"
+	MethodSet       = "Set"
+	MethodSnapshot  = "Snapshot"
+)
+
+// Call is a recorded call of Collector
+type Call struct {
+	Method string
+	Key    string
+	// Value is an argument of Add, Gauge and Histogram
+	Value float64
+	// Values are arguments of Set and Snapshot
+	Values []string
+}
+
+// Recorder is implemented collect.Collector, records every call in order
+// and collects metrics by SimpleCollector
+type Recorder struct {
+	c     *collect.SimpleCollector
+	calls []Call
+	mu    sync.Mutex
+}
+
+// NewRecorder return new Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		c:     collect.NewSimpleCollector(),
+		calls: make([]Call, 0),
+	}
+}
+
+// Calls return recorded calls in order
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]Call, len(r.calls))
+	copy(res, r.calls)
+	return res
+}
+
+// Reset remove recorded calls and metrics
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c = collect.NewSimpleCollector()
+	r.calls = make([]Call, 0)
+}
+
+// record add the call and return the collector to apply it
+func (r *Recorder) record(call Call) *collect.SimpleCollector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	return r.c
+}
+
+func (r *Recorder) collector() *collect.SimpleCollector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c
+}
+
+// GetMetrics returns json from encoded metrics
+func (r *Recorder) GetMetrics(key string) ([]byte, error) {
+	return r.collector().GetMetrics(key)
+}
+
+// GetMetricsKeys return all metrics keys
+func (r *Recorder) GetMetricsKeys() []string {
+	return r.collector().GetMetricsKeys()
+}
+
+// Add record and add count for CounterMetrics
+func (r *Recorder) Add(key string, delta float64) {
+	r.record(Call{Method: MethodAdd, Key: key, Value: delta}).Add(key, delta)
+}
+
+// Gauge record and set metrics for GaugeMetrics
+func (r *Recorder) Gauge(key string, delta float64) {
+	r.record(Call{Method: MethodGauge, Key: key, Value: delta}).Gauge(key, delta)
+}
+
+// Histogram record and add metrics for Histogram
+func (r *Recorder) Histogram(key string, delta float64) {
+	r.record(Call{Method: MethodHistogram, Key: key, Value: delta}).Histogram(key, delta)
+}
+
+// Set record and add metrics for Set
+func (r *Recorder) Set(key string, delta string) {
+	r.record(Call{Method: MethodSet, Key: key, Values: []string{delta}}).Set(key, delta)
+}
+
+// Snapshot record and add metrics for Snapshot
+func (r *Recorder) Snapshot(key string, deltas []string) {
+	values := make([]string, len(deltas))
+	copy(values, deltas)
+	r.record(Call{Method: MethodSnapshot, Key: key, Values: values}).Snapshot(key, deltas)
+}
+
+// Discard is a collect.Collector on which all calls succeed without doing anything
+var Discard collect.Collector = discard{}
+
+type discard struct{}
+
+func (discard) GetMetrics(string) ([]byte, error) { return nil, collect.ErrNotFoundMetrics }
+func (discard) GetMetricsKeys() []string          { return []string{} }
+func (discard) Add(string, float64)               {}
+func (discard) Gauge(string, float64)             {}
+func (discard) Histogram(string, float64)         {}
+func (discard) Set(string, string)                {}
+func (discard) Snapshot(string, []string)         {}
+
+// AssertCounter report an error when the counter of the key is not want
+func AssertCounter(t testing.TB, c collect.Collector, key string, want float64) {
+	t.Helper()
+	assertFloat(t, c, key, key, want)
+}
+
+// AssertGauge report an error when the gauge of the key is not want
+func AssertGauge(t testing.TB, c collect.Collector, key string, want float64) {
+	t.Helper()
+	assertFloat(t, c, key, key, want)
+}
+
+// AssertHistogramCount report an error when the number of histogram values of the key is not want
+func AssertHistogramCount(t testing.TB, c collect.Collector, key string, want int64) {
+	t.Helper()
+	assertFloat(t, c, key, key+".count", float64(want))
+}
+
+// AssertSetContains report an error when the set of the key does not contain all values
+func AssertSetContains(t testing.TB, c collect.Collector, key string, values ...string) {
+	t.Helper()
+	raw, ok := field(t, c, key, key)
+	if !ok {
+		return
+	}
+	var got []string
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Errorf("%s: want set, got %s", key, raw)
+		return
+	}
+	members := make(map[string]struct{}, len(got))
+	for _, v := range got {
+		members[v] = struct{}{}
+	}
+	for _, v := range values {
+		if _, ok := members[v]; !ok {
+			t.Errorf("%s: want to contain %q, got %v", key, v, got)
+		}
+	}
+}
+
+// assertFloat report an error when the number of the aggregated key is not want
+func assertFloat(t testing.TB, c collect.Collector, key, name string, want float64) {
+	t.Helper()
+	raw, ok := field(t, c, key, name)
+	if !ok {
+		return
+	}
+	got, err := parseFloat(raw)
+	if err != nil {
+		t.Errorf("%s: want number, got %s", name, raw)
+		return
+	}
+	if got != want {
+		t.Errorf("%s: want %v, got %v", name, want, got)
+	}
+}
+
+// field return encoded value of the aggregated key in metrics of the key
+func field(t testing.TB, c collect.Collector, key, name string) (json.RawMessage, bool) {
+	t.Helper()
+	b, err := c.GetMetrics(key)
+	if err != nil {
+		t.Errorf("%s: want metrics, got error %v", key, err)
+		return nil, false
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Errorf("%s: want json object, got %s", key, b)
+		return nil, false
+	}
+	raw, ok := m[name]
+	if !ok {
+		t.Errorf("%s: want %s in %s", key, name, b)
+		return nil, false
+	}
+	return raw, true
+}
+
+// parseFloat return a number, "NaN", "+Inf" and "-Inf" are encoded as string
+func parseFloat(raw json.RawMessage) (float64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strconv.ParseFloat(s, 64)
+	}
+	var f float64
+	err := json.Unmarshal(raw, &f)
+	return f, err
+}
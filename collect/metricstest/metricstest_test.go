@@ -0,0 +1,101 @@
+package metricstest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/takashabe/go-metrics/collect"
+)
+
+// fakeT records errors instead of failing the test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	var c collect.Collector = r
+	c.Add("a", 1)
+	c.Add("a", 2)
+	c.Gauge("g", 1.5)
+	c.Histogram("h", 3)
+	c.Set("s", "x")
+	c.Snapshot("ss", []string{"y", "z"})
+
+	expect := []Call{
+		{Method: MethodAdd, Key: "a", Value: 1},
+		{Method: MethodAdd, Key: "a", Value: 2},
+		{Method: MethodGauge, Key: "g", Value: 1.5},
+		{Method: MethodHistogram, Key: "h", Value: 3},
+		{Method: MethodSet, Key: "s", Values: []string{"x"}},
+		{Method: MethodSnapshot, Key: "ss", Values: []string{"y", "z"}},
+	}
+	if got := r.Calls(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	if got, expect := r.GetMetricsKeys(), []string{"a", "g", "h", "s", "ss"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+
+	AssertCounter(t, r, "a", 3)
+	AssertGauge(t, r, "g", 1.5)
+	AssertHistogramCount(t, r, "h", 1)
+	AssertSetContains(t, r, "s", "x")
+
+	r.Reset()
+	if got := len(r.Calls()); got != 0 {
+		t.Errorf("want no calls, got %d", got)
+	}
+	if got := len(r.GetMetricsKeys()); got != 0 {
+		t.Errorf("want no keys, got %d", got)
+	}
+}
+
+func TestAssertFailure(t *testing.T) {
+	c := collect.NewSimpleCollector()
+	c.Add("a", 1)
+	c.Histogram("h", 1)
+	c.Set("s", "x")
+
+	cases := []struct {
+		assert func(testing.TB)
+		errors int
+	}{
+		{func(t testing.TB) { AssertCounter(t, c, "a", 1) }, 0},
+		{func(t testing.TB) { AssertCounter(t, c, "a", 2) }, 1},
+		{func(t testing.TB) { AssertCounter(t, c, "unknown", 1) }, 1},
+		{func(t testing.TB) { AssertHistogramCount(t, c, "h", 2) }, 1},
+		{func(t testing.TB) { AssertHistogramCount(t, c, "a", 1) }, 1},
+		{func(t testing.TB) { AssertSetContains(t, c, "s", "x", "y", "z") }, 2},
+		{func(t testing.TB) { AssertSetContains(t, c, "a", "x") }, 1},
+	}
+	for i, tc := range cases {
+		ft := &fakeT{TB: t}
+		tc.assert(ft)
+		if len(ft.errors) != tc.errors {
+			t.Errorf("#%d: want %d errors, got %v", i, tc.errors, ft.errors)
+		}
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	Discard.Add("a", 1)
+	Discard.Gauge("a", 1)
+	Discard.Histogram("a", 1)
+	Discard.Set("a", "x")
+	Discard.Snapshot("a", []string{"x"})
+	if _, err := Discard.GetMetrics("a"); err != collect.ErrNotFoundMetrics {
+		t.Errorf("want error %v, got %v", collect.ErrNotFoundMetrics, err)
+	}
+	if got := Discard.GetMetricsKeys(); len(got) != 0 {
+		t.Errorf("want no keys, got %v", got)
+	}
+}
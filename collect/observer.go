@@ -0,0 +1,72 @@
+package collect
+
+// Event is a recorded metrics notified to Observer
+type Event struct {
+	Key  string
+	Type MetricType
+	// Value is a recorded value of Counter, Gauge and Histogram
+	Value float64
+	// Values are recorded values of Set, Snapshot, TopK, Tally and StateSet
+	Values []string
+	// Labels are recorded labels of Info
+	Labels map[string]string
+	// Exemplar is a recorded exemplar of Counter and Histogram, nil when not recorded
+	Exemplar *Exemplar
+	// SampleRate is a rate of AddSampled and HistogramSampled, 0 when recorded by other methods.
+	// Value is not scaled by the rate, e.g. StatsD exporters can emit `key:1|c|@0.1`
+	SampleRate float64
+	// Count is a number of observations of HistogramN and ObserveN, 0 when recorded by other methods
+	Count int64
+}
+
+// Observer is notified every recorded metrics
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc is an adapter to use ordinary functions as Observer
+type ObserverFunc func(Event)
+
+// Observe call f(e)
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// observerEntry is a registered Observer, used to identify it when removed
+type observerEntry struct {
+	observer Observer
+}
+
+// AddObserver register Observer, and return a function to unregister it.
+// observers are called after recorded without holding the collector lock
+func (c *SimpleCollector) AddObserver(o Observer) func() {
+	e := &observerEntry{observer: o}
+	c.observerMu.Lock()
+	defer c.observerMu.Unlock()
+	c.observers = append(c.observers, e)
+
+	return func() {
+		c.observerMu.Lock()
+		defer c.observerMu.Unlock()
+		for i, v := range c.observers {
+			if v == e {
+				// copy to keep the slice used by running notify()
+				observers := make([]*observerEntry, 0, len(c.observers)-1)
+				observers = append(observers, c.observers[:i]...)
+				c.observers = append(observers, c.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify call all observers, expect to be called without lock
+func (c *SimpleCollector) notify(e Event) {
+	c.observerMu.RLock()
+	observers := c.observers
+	c.observerMu.RUnlock()
+
+	for _, v := range observers {
+		v.observer.Observe(e)
+	}
+}
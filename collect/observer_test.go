@@ -0,0 +1,66 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	c := NewSimpleCollector()
+	var events []Event
+	remove := c.AddObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	c.Add("c", 1)
+	c.Gauge("g", 2)
+	c.Histogram("h", 3)
+	c.Set("s", "a")
+	c.Snapshot("ss", []string{"b", "c"})
+	// expect: not notified ignored metrics
+	c.Gauge("c", 1)
+
+	expect := []Event{
+		{Key: "c", Type: TypeCounter, Value: 1},
+		{Key: "g", Type: TypeGauge, Value: 2},
+		{Key: "h", Type: TypeHistogram, Value: 3},
+		{Key: "s", Type: TypeSet, Values: []string{"a"}},
+		{Key: "ss", Type: TypeSnapshot, Values: []string{"b", "c"}},
+	}
+	if !reflect.DeepEqual(events, expect) {
+		t.Errorf("want %v, got %v", expect, events)
+	}
+
+	remove()
+	c.Add("c", 1)
+	if len(events) != len(expect) {
+		t.Errorf("want removed observer, got %v", events[len(expect):])
+	}
+}
+
+func TestObserverWithoutLock(t *testing.T) {
+	c := NewSimpleCollector()
+	mirror := NewSimpleCollector()
+	c.AddObserver(ObserverFunc(func(e Event) {
+		// expect: no deadlock when the collector is used in observer
+		if _, err := c.GetMetrics(e.Key); err != nil {
+			t.Errorf("want no error, got %v", err)
+		}
+		mirror.Add(e.Key, e.Value)
+	}))
+	var removeSelf func()
+	removeSelf = c.AddObserver(ObserverFunc(func(e Event) {
+		removeSelf()
+	}))
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+
+	got, err := mirror.GetMetrics("a")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"a":3}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
@@ -0,0 +1,109 @@
+package collect
+
+import (
+	"time"
+)
+
+// Reset set count to zero
+func (m *CounterMetrics) Reset() {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.f = 0
+	m.exemplar = nil
+}
+
+// Reset set value to zero
+func (m *GaugeMetrics) Reset() {
+	m.set(0, time.Time{})
+}
+
+// Reset remove all values and exemplars
+func (m *HistogramMetrics) Reset() {
+	m.value.Reset()
+	m.exemplars.reset()
+}
+
+// Reset remove all values
+func (m *SetMetrics) Reset() {
+	m.value.reset()
+}
+
+// Reset remove all values
+func (m *SnapshotMetrics) Reset() {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.v = make(map[string]struct{})
+	m.updated = time.Time{}
+}
+
+// Reset remove all values
+func (s *FloatSlice) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v = make([]float64, 0)
+	s.w = nil
+}
+
+// Reset clear all counts
+func (s *HDRSample) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.counts {
+		s.counts[i] = 0
+	}
+	s.total = 0
+}
+
+// Reset remove all values and restart decay from now
+func (s *ExpDecaySample) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = &priorityHeap{}
+	s.count = 0
+	s.landmark = s.now()
+	s.nextRescale = s.landmark.Add(rescaleThreshold)
+}
+
+func (m *Map) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.v = make(map[string]struct{})
+}
+
+func (r *exemplarBuckets) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.v = nil
+}
+
+// Delete remove the metrics
+func (c *SimpleCollector) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.metrics[key]; !ok {
+		return ErrNotFoundMetrics
+	}
+	delete(c.metrics, key)
+	return nil
+}
+
+// Reset reset values of the metrics, the key and type are kept
+func (c *SimpleCollector) Reset(key string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.metrics[key]
+	if !ok {
+		return ErrNotFoundMetrics
+	}
+	m.Reset()
+	return nil
+}
+
+// ResetAll reset values of all metrics
+func (c *SimpleCollector) ResetAll() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, m := range c.metrics {
+		m.Reset()
+	}
+}
@@ -0,0 +1,74 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Add("a", 1)
+	c.Add("b", 1)
+
+	if err := c.Delete("a"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.Delete("a"); err != ErrNotFoundMetrics {
+		t.Fatalf("want error %v, got %v", ErrNotFoundMetrics, err)
+	}
+	if expect, got := []string{"b"}, c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want keys %v, got %v", expect, got)
+	}
+
+	// expect: can add deleted key with other type
+	c.Gauge("a", 2)
+	if got := c.metrics["a"].GetType(); got != TypeGauge {
+		t.Errorf("want type %s, got %s", TypeGauge, got)
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := createMixedCollector(t)
+	c.AddWithExemplar("c", 1, Exemplar{TraceID: "a"})
+
+	if err := c.Reset("unknown"); err != ErrNotFoundMetrics {
+		t.Fatalf("want error %v, got %v", ErrNotFoundMetrics, err)
+	}
+	if err := c.Reset("c"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	got, err := c.GetMetrics("c")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"c":0}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+
+	c.ResetAll()
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"eh", []byte(`{"eh.95percentile":0,"eh.avg":0,"eh.count":0,"eh.max":0,"eh.median":0}`)},
+		{"g", []byte(`{"g":0}`)},
+		{"h", []byte(`{"h.95percentile":0,"h.avg":0,"h.count":0,"h.max":0,"h.median":0}`)},
+		{"s", []byte(`{"s":[]}`)},
+		{"ss", []byte(`{"ss":[]}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+
+	// expect: keep type after reset
+	c.Gauge("c", 1)
+	if got := c.metrics["c"].GetType(); got != TypeCounter {
+		t.Errorf("want type %s, got %s", TypeCounter, got)
+	}
+}
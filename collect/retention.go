@@ -0,0 +1,172 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Point is aggregated metrics of the key at the time
+type Point struct {
+	Timestamp time.Time
+	// Value is encoded json, the same as GetMetrics
+	Value []byte
+}
+
+// MarshalJSON return specific encoded json, timestamp is unix time in seconds
+func (p *Point) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"timestamp":`)
+	buf.Write(formatFloat(float64(p.Timestamp.UnixNano())/float64(time.Second), ShortestPrecision))
+	buf.WriteString(`,"value":`)
+	buf.Write(p.Value)
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// pointRing keeps recent points of the key
+type pointRing struct {
+	v    []Point
+	next int
+}
+
+func (r *pointRing) add(p Point, size int) {
+	if len(r.v) < size {
+		r.v = append(r.v, p)
+		return
+	}
+	r.v[r.next] = p
+	r.next = (r.next + 1) % size
+}
+
+// latest return the most recent point
+func (r *pointRing) latest() Point {
+	if len(r.v) < cap(r.v) || r.next == 0 {
+		return r.v[len(r.v)-1]
+	}
+	return r.v[r.next-1]
+}
+
+// list return points ordered by timestamp
+func (r *pointRing) list() []Point {
+	res := make([]Point, 0, len(r.v))
+	res = append(res, r.v[r.next:]...)
+	return append(res, r.v[:r.next]...)
+}
+
+// seriesStore keeps points of each key
+type seriesStore struct {
+	resolution time.Duration
+	retention  time.Duration
+	size       int
+	rings      map[string]*pointRing
+	mu         sync.RWMutex
+}
+
+// SetRetention keep points of each key every resolution up to retention, and remove kept points.
+// points are recorded by RunRetention, and disabled when resolution or retention is not positive
+func (c *SimpleCollector) SetRetention(resolution, retention time.Duration) {
+	var s *seriesStore
+	if resolution > 0 && retention > 0 {
+		size := int(retention / resolution)
+		if size < 1 {
+			size = 1
+		}
+		s = &seriesStore{
+			resolution: resolution,
+			retention:  retention,
+			size:       size,
+			rings:      make(map[string]*pointRing),
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series = s
+}
+
+// RunRetention run a goroutine to record points of all keys every resolution of SetRetention
+func (c *SimpleCollector) RunRetention(ctx context.Context) {
+	c.mu.RLock()
+	s, clock := c.series, c.clock
+	c.mu.RUnlock()
+	if s == nil {
+		return
+	}
+
+	go func() {
+		t := clock.NewTicker(s.resolution)
+		for {
+			select {
+			case now := <-t.Chan():
+				c.recordPoints(now)
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// recordPoints add aggregated metrics of all keys at the time truncated by the resolution
+func (c *SimpleCollector) recordPoints(now time.Time) {
+	c.mu.RLock()
+	s := c.series
+	if s == nil {
+		c.mu.RUnlock()
+		return
+	}
+	values := make(map[string][]byte, len(c.metrics))
+	for k, m := range c.metrics {
+		v, err := marshalJSONWithOrder(m.Aggregate(), c.precision)
+		if err != nil {
+			continue
+		}
+		values[k] = v
+	}
+	c.mu.RUnlock()
+
+	now = now.Truncate(s.resolution)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range values {
+		r, ok := s.rings[k]
+		if !ok {
+			r = &pointRing{v: make([]Point, 0, s.size)}
+			s.rings[k] = r
+		}
+		r.add(Point{Timestamp: now, Value: v}, s.size)
+	}
+
+	// remove series of deleted keys after retention
+	for k, r := range s.rings {
+		if _, ok := values[k]; !ok && !r.latest().Timestamp.After(now.Add(-s.retention)) {
+			delete(s.rings, k)
+		}
+	}
+}
+
+// QuerySeries return points of the key between from and to inclusive, ordered by timestamp
+func (c *SimpleCollector) QuerySeries(key string, from, to time.Time) ([]Point, error) {
+	c.mu.RLock()
+	s := c.series
+	c.mu.RUnlock()
+	if s == nil {
+		return nil, ErrNotFoundMetrics
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rings[key]
+	if !ok {
+		return nil, ErrNotFoundMetrics
+	}
+	res := make([]Point, 0)
+	for _, p := range r.list() {
+		if p.Timestamp.Before(from) || p.Timestamp.After(to) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
@@ -0,0 +1,135 @@
+package collect
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuerySeries(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetRetention(time.Second, 3*time.Second)
+	base := time.Unix(1500000000, 0)
+	for i := 0; i < 5; i++ {
+		c.Add("a", 1)
+		if i < 2 {
+			c.Gauge("b", float64(i))
+		}
+		// truncated by the resolution
+		c.recordPoints(base.Add(time.Duration(i)*time.Second + 100*time.Millisecond))
+	}
+
+	cases := []struct {
+		key    string
+		from   time.Time
+		to     time.Time
+		expect string
+		err    error
+	}{
+		{
+			"a", base, base.Add(time.Hour),
+			`[{"timestamp":1500000002,"value":{"a":3}},{"timestamp":1500000003,"value":{"a":4}},{"timestamp":1500000004,"value":{"a":5}}]`,
+			nil,
+		},
+		{
+			"a", base.Add(3 * time.Second), base.Add(3 * time.Second),
+			`[{"timestamp":1500000003,"value":{"a":4}}]`,
+			nil,
+		},
+		{
+			"a", base, base.Add(time.Second),
+			`[]`,
+			nil,
+		},
+		{
+			"b", base, base.Add(time.Hour),
+			`[{"timestamp":1500000002,"value":{"b":1}},{"timestamp":1500000003,"value":{"b":1}},{"timestamp":1500000004,"value":{"b":1}}]`,
+			nil,
+		},
+		{"c", base, base.Add(time.Hour), "", ErrNotFoundMetrics},
+	}
+	for i, tc := range cases {
+		got, err := c.QuerySeries(tc.key, tc.from, tc.to)
+		if err != tc.err {
+			t.Fatalf("#%d: want error %v, got %v", i, tc.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if s := encodePoints(t, got); s != tc.expect {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, s)
+		}
+	}
+}
+
+func TestQuerySeriesDeletedKey(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetRetention(time.Second, 2*time.Second)
+	base := time.Unix(1500000000, 0)
+	c.Add("a", 1)
+	c.recordPoints(base)
+	if err := c.Delete("a"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	cases := []struct {
+		now time.Time
+		err error
+	}{
+		{base.Add(time.Second), nil},
+		{base.Add(2 * time.Second), ErrNotFoundMetrics},
+	}
+	for i, tc := range cases {
+		c.recordPoints(tc.now)
+		if _, err := c.QuerySeries("a", base, tc.now); err != tc.err {
+			t.Errorf("#%d: want error %v, got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestRetentionDisabled(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Add("a", 1)
+	c.RunRetention(context.Background())
+	c.recordPoints(time.Now())
+	if _, err := c.QuerySeries("a", time.Time{}, time.Now()); err != ErrNotFoundMetrics {
+		t.Errorf("want error %v, got %v", ErrNotFoundMetrics, err)
+	}
+}
+
+func encodePoints(t *testing.T, points []Point) string {
+	var buf []byte
+	buf = append(buf, '[')
+	for i := range points {
+		if i != 0 {
+			buf = append(buf, ',')
+		}
+		b, err := points[i].MarshalJSON()
+		if err != nil {
+			t.Fatalf("want no error, got %v", err)
+		}
+		buf = append(buf, b...)
+	}
+	buf = append(buf, ']')
+	return string(buf)
+}
+
+func TestPointRing(t *testing.T) {
+	r := &pointRing{v: make([]Point, 0, 3)}
+	base := time.Unix(0, 0)
+	for i := 0; i < 4; i++ {
+		r.add(Point{Timestamp: base.Add(time.Duration(i) * time.Second)}, 3)
+	}
+	expect := []time.Time{base.Add(time.Second), base.Add(2 * time.Second), base.Add(3 * time.Second)}
+	got := make([]time.Time, 0)
+	for _, p := range r.list() {
+		got = append(got, p.Timestamp)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	if l := r.latest().Timestamp; !l.Equal(expect[2]) {
+		t.Errorf("want latest %v, got %v", expect[2], l)
+	}
+}
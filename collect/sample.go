@@ -0,0 +1,308 @@
+package collect
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sample is a storage strategy of histogram values
+type Sample interface {
+	// Update add a value
+	Update(float64)
+	// Values return sorted copy of kept values
+	Values() []float64
+	// Count return number of all updated values
+	Count() int64
+	// Merge add values of other Sample
+	Merge(Sample) error
+	// Reset remove all values
+	Reset()
+}
+
+// WeightedSample is Sample keeps weighted values, used by HistogramN
+type WeightedSample interface {
+	Sample
+	// UpdateN add a value observed n times
+	UpdateN(v float64, n int64)
+	// WeightedValues return sorted copy of kept values and their weights
+	WeightedValues() ([]float64, []int64)
+}
+
+// nUpdater is Sample added a value n times at once, e.g. WeightedSample and ExpDecaySample
+type nUpdater interface {
+	UpdateN(v float64, n int64)
+}
+
+// updateN add a value n times, using UpdateN when s supports it.
+// otherwise Update is called n times
+func updateN(s Sample, v float64, n int64) {
+	if u, ok := s.(nUpdater); ok {
+		u.UpdateN(v, n)
+		return
+	}
+	for i := int64(0); i < n; i++ {
+		s.Update(v)
+	}
+}
+
+// weightedFloats is sorted values with cumulative weights
+type weightedFloats struct {
+	v   []float64
+	cum []int64
+}
+
+// newWeightedFloats return weightedFloats of sorted values and their weights
+func newWeightedFloats(values []float64, weights []int64) weightedFloats {
+	cum := make([]int64, len(weights))
+	var total int64
+	for i, w := range weights {
+		total += w
+		cum[i] = total
+	}
+	return weightedFloats{v: values, cum: cum}
+}
+
+// total return sum of weights
+func (l weightedFloats) total() int64 {
+	if len(l.cum) == 0 {
+		return 0
+	}
+	return l.cum[len(l.cum)-1]
+}
+
+// at return the k-th value of values repeated by their weights, k is 0-based
+func (l weightedFloats) at(k int64) float64 {
+	i := sort.Search(len(l.cum), func(i int) bool { return l.cum[i] > k })
+	return l.v[i]
+}
+
+func (l weightedFloats) average() float64 {
+	total := l.total()
+	if total == 0 {
+		return 0
+	}
+	var sum float64
+	var prev int64
+	for i, v := range l.v {
+		sum += v * float64(l.cum[i]-prev)
+		prev = l.cum[i]
+	}
+	return sum / float64(total)
+}
+
+func (l weightedFloats) max() float64 {
+	var max float64
+	if size := len(l.v); size > 0 {
+		max = l.v[size-1]
+	}
+	return max
+}
+
+func (l weightedFloats) median() float64 {
+	var median float64
+	if total := l.total(); total > 0 {
+		median = l.at(total / 2)
+	}
+	return median
+}
+
+// percentile is the same as sortedFloats.percentile of values repeated by their weights
+func (l weightedFloats) percentile(n float64) float64 {
+	total := l.total()
+	if 1.0 <= n || total < minPercentileSize {
+		return 0
+	}
+
+	r := 1 + float64(total-1)*n
+	rFloor := int64(math.Floor(r))
+	rCeil := int64(math.Ceil(r))
+	lower := l.at(rFloor - 1)
+	return lower + (r-float64(rFloor))*(l.at(rCeil-1)-lower)
+}
+
+// rescaleThreshold is interval of rescale priorities for ExpDecaySample
+const rescaleThreshold = time.Hour
+
+// ExpDecaySample is implemented Sample.
+// it is a forward-decaying priority reservoir with fixed size, weighted towards recent values.
+// see http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf
+type ExpDecaySample struct {
+	size        int
+	alpha       float64
+	count       int64
+	landmark    time.Time
+	nextRescale time.Time
+	values      *priorityHeap
+
+	now  func() time.Time
+	rand *rand.Rand
+	mu   sync.Mutex
+}
+
+// NewExpDecaySample return new ExpDecaySample using SystemClock, size less than 1 is treated as 1.
+// larger alpha is more biased towards recent values, e.g. size 1028 and alpha 0.015 is
+// representative of the last 5 minutes
+func NewExpDecaySample(size int, alpha float64) *ExpDecaySample {
+	return NewExpDecaySampleWithClock(size, alpha, SystemClock)
+}
+
+// NewExpDecaySampleWithClock return new ExpDecaySample decayed by the time of clock.
+// the clock is replaced by Clock of the collector when the sample is registered
+func NewExpDecaySampleWithClock(size int, alpha float64, clock Clock) *ExpDecaySample {
+	if size < 1 {
+		size = 1
+	}
+	s := &ExpDecaySample{
+		size:   size,
+		alpha:  alpha,
+		values: &priorityHeap{},
+		now:    clock.Now,
+		rand:   rand.New(rand.NewSource(clock.Now().UnixNano())),
+	}
+	s.landmark = s.now()
+	s.nextRescale = s.landmark.Add(rescaleThreshold)
+	return s
+}
+
+// setClock replace the source of the time, kept priorities are rescaled to the time of clock
+func (s *ExpDecaySample) setClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = clock.Now
+	t := s.now()
+	if s.values.Len() == 0 {
+		s.landmark = t
+		s.nextRescale = t.Add(rescaleThreshold)
+		return
+	}
+	s.rescale(t)
+}
+
+// clockSetter is Sample decayed by the time, e.g. ExpDecaySample
+type clockSetter interface {
+	setClock(Clock)
+}
+
+// setSampleClock set the clock to the Sample decayed by the time
+func setSampleClock(s Sample, clock Clock) {
+	if cs, ok := s.(clockSetter); ok {
+		cs.setClock(clock)
+	}
+}
+
+// Update add a value with priority based on the current time
+func (s *ExpDecaySample) Update(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.now()
+	if !t.Before(s.nextRescale) {
+		s.rescale(t)
+	}
+	s.count++
+
+	s.offer(v, s.priority(t))
+}
+
+// UpdateN add a value observed n times at once. the value is offered at most size times with
+// the highest priorities of n observations, so it is the same as Update n times without n loops
+func (s *ExpDecaySample) UpdateN(v float64, n int64) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.now()
+	if !t.Before(s.nextRescale) {
+		s.rescale(t)
+	}
+	s.count += n
+
+	k := n
+	if k > int64(s.size) {
+		k = int64(s.size)
+	}
+	w := math.Exp(s.alpha * t.Sub(s.landmark).Seconds())
+	// u is the smallest k of n uniform random numbers in ascending order,
+	// the minimum of m uniform random numbers is 1 - r^(1/m)
+	var u float64
+	for j := int64(0); j < k; j++ {
+		u += (1 - u) * (1 - math.Pow(s.rand.Float64(), 1/float64(n-j)))
+		s.offer(v, w/u)
+	}
+}
+
+// priority return random priority of a value updated at t
+func (s *ExpDecaySample) priority(t time.Time) float64 {
+	// (0, 1] avoids division by zero
+	u := 1 - s.rand.Float64()
+	return math.Exp(s.alpha*t.Sub(s.landmark).Seconds()) / u
+}
+
+// offer keep a value when priority is higher than the lowest priority
+func (s *ExpDecaySample) offer(v, p float64) {
+	if s.values.Len() < s.size {
+		heap.Push(s.values, prioritized{value: v, priority: p})
+		return
+	}
+	// replace the lowest priority value
+	if min := (*s.values)[0]; min.priority < p {
+		(*s.values)[0] = prioritized{value: v, priority: p}
+		heap.Fix(s.values, 0)
+	}
+}
+
+// rescale moves landmark to t, to avoid overflow of priorities
+func (s *ExpDecaySample) rescale(t time.Time) {
+	factor := math.Exp(-s.alpha * t.Sub(s.landmark).Seconds())
+	for i := range *s.values {
+		(*s.values)[i].priority *= factor
+	}
+	s.landmark = t
+	s.nextRescale = t.Add(rescaleThreshold)
+}
+
+// Values return sorted copy of kept values
+func (s *ExpDecaySample) Values() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]float64, 0, s.values.Len())
+	for _, v := range *s.values {
+		res = append(res, v.value)
+	}
+	sort.Float64s(res)
+	return res
+}
+
+// Count return number of all updated values
+func (s *ExpDecaySample) Count() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// prioritized is a value with priority in ExpDecaySample
+type prioritized struct {
+	value    float64
+	priority float64
+}
+
+// priorityHeap is implemented heap.Interface, the lowest priority is the root
+type priorityHeap []prioritized
+
+func (h priorityHeap) Len() int            { return len(h) }
+func (h priorityHeap) Less(i, j int) bool  { return h[i].priority < h[j].priority }
+func (h priorityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x interface{}) { *h = append(*h, x.(prioritized)) }
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
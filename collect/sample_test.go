@@ -0,0 +1,227 @@
+package collect
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func createFixedExpDecaySample(size int, alpha float64, clock Clock) *ExpDecaySample {
+	s := NewExpDecaySampleWithClock(size, alpha, clock)
+	s.rand = rand.New(rand.NewSource(1))
+	return s
+}
+
+func TestExpDecaySampleSize(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	cases := []struct {
+		size        int
+		updates     int
+		expectSize  int
+		expectCount int64
+	}{
+		{10, 5, 5, 5},
+		{10, 10, 10, 10},
+		{10, 1000, 10, 1000},
+	}
+	for i, c := range cases {
+		s := createFixedExpDecaySample(c.size, 0.015, clock)
+		for j := 0; j < c.updates; j++ {
+			s.Update(float64(j))
+		}
+		if got := len(s.Values()); got != c.expectSize {
+			t.Errorf("#%d: want size %d, got %d", i, c.expectSize, got)
+		}
+		if got := s.Count(); got != c.expectCount {
+			t.Errorf("#%d: want count %d, got %d", i, c.expectCount, got)
+		}
+	}
+}
+
+func TestExpDecaySampleInvalidSize(t *testing.T) {
+	for i, size := range []int{0, -1} {
+		s := NewExpDecaySample(size, 0.015)
+		s.Update(1)
+		s.Update(2)
+		if got, expect := len(s.Values()), 1; got != expect {
+			t.Errorf("#%d: want size %d, got %d", i, expect, got)
+		}
+	}
+}
+
+func TestExpDecaySampleRecentValues(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	s := createFixedExpDecaySample(100, 0.1, clock)
+
+	// old values
+	for i := 0; i < 1000; i++ {
+		s.Update(1)
+	}
+	// recent values, over rescaleThreshold
+	clock.Advance(2 * rescaleThreshold)
+	for i := 0; i < 1000; i++ {
+		s.Update(2)
+	}
+
+	for _, v := range s.Values() {
+		if v != 2 {
+			t.Fatalf("want only recent values, got %v", s.Values())
+		}
+	}
+	if !s.landmark.Equal(clock.Now()) {
+		t.Errorf("want landmark %v, got %v", clock.Now(), s.landmark)
+	}
+}
+
+func TestRegisterHistogram(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewSimpleCollector()
+	c.SetClock(clock)
+	if err := c.RegisterHistogram("h", createFixedExpDecaySample(2, 0.015, clock)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.RegisterHistogram("h", NewExpDecaySample(2, 0.015)); err != ErrAlreadyExistMetrics {
+		t.Fatalf("want error %v, got %v", ErrAlreadyExistMetrics, err)
+	}
+	for _, v := range []float64{1, 2, 3} {
+		c.Histogram("h", v)
+	}
+
+	// expect: count is all updated values, others are calculated by reservoir
+	agg := c.metrics["h"].Aggregate()
+	got, err := agg["h.count"].MarshalJSON()
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte("3"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+	if size := len(c.metrics["h"].(*HistogramMetrics).value.Values()); size != 2 {
+		t.Errorf("want reservoir size %d, got %d", 2, size)
+	}
+}
+
+func TestHistogramN(t *testing.T) {
+	type observation struct {
+		v float64
+		n int64
+	}
+	cases := []struct {
+		input []observation
+	}{
+		{[]observation{{1, 1}}},
+		{[]observation{{1, 1}, {3, 1}}},
+		{[]observation{{5, 3}, {1, 2}}},
+		{[]observation{{2, 1}, {10, 500}, {1, 4}, {7, 1}}},
+		{[]observation{{0.25, 7}, {0.5, 0}, {0.125, 13}, {1, 1}}},
+	}
+	for i, tc := range cases {
+		weighted := NewSimpleCollector()
+		expanded := NewSimpleCollector()
+		for _, o := range tc.input {
+			weighted.HistogramN("h", o.v, o.n)
+			for j := int64(0); j < o.n; j++ {
+				expanded.Histogram("h", o.v)
+			}
+		}
+		got, err := weighted.GetMetrics("h")
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		expect, err := expanded.GetMetrics("h")
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %s, got %s", i, expect, got)
+		}
+	}
+}
+
+func TestHistogramNKeepsValueOnce(t *testing.T) {
+	c := NewSimpleCollector()
+	c.Histogram("h", 1)
+	c.HistogramN("h", 2, 500)
+	c.NewHistogram("h").ObserveN(3, 10)
+
+	s := c.metrics["h"].(*HistogramMetrics).value.(*FloatSlice)
+	if got, expect := s.Values(), []float64{1, 2, 3}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	if got, expect := s.Count(), int64(511); got != expect {
+		t.Errorf("want %d, got %d", expect, got)
+	}
+
+	// merge and state keep weights
+	merged := NewSimpleCollector()
+	merged.HistogramN("h", 4, 2)
+	if err := merged.Merge(c); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := merged.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	restored := NewSimpleCollector()
+	if err := restored.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := []byte(`{"h.95percentile":2,"h.avg":2.0253411306042883,"h.count":513,"h.max":4,"h.median":2}`)
+	for i, sc := range []*SimpleCollector{merged, restored} {
+		got, err := sc.GetMetrics("h")
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %s, got %s", i, expect, got)
+		}
+	}
+
+	// reset remove weights
+	c.Reset("h")
+	c.Histogram("h", 1)
+	if got, expect := s.Count(), int64(1); got != expect {
+		t.Errorf("want %d, got %d", expect, got)
+	}
+}
+
+func TestHistogramNWithExpDecaySample(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewSimpleCollector()
+	c.SetClock(clock)
+	if err := c.RegisterHistogram("h", createFixedExpDecaySample(4, 0.015, clock)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	c.HistogramN("h", 1, 10)
+
+	// expect: added n times to the reservoir
+	s := c.metrics["h"].(*HistogramMetrics).value
+	if got, expect := s.Count(), int64(10); got != expect {
+		t.Errorf("want %d, got %d", expect, got)
+	}
+	if got, expect := s.Values(), []float64{1, 1, 1, 1}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+}
+
+func TestExpDecaySampleUpdateNLarge(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewSimpleCollector()
+	c.SetClock(clock)
+	if err := c.RegisterHistogram("h", createFixedExpDecaySample(4, 0.015, clock)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	c.Histogram("h", 2)
+	c.HistogramN("h", 1, 1e9)
+
+	// expect: not updated 1e9 times, the value fills the reservoir
+	s := c.metrics["h"].(*HistogramMetrics).value
+	if got, expect := s.Count(), int64(1e9+1); got != expect {
+		t.Errorf("want %d, got %d", expect, got)
+	}
+	if got, expect := s.Values(), []float64{1, 1, 1, 1}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+}
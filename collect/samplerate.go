@@ -0,0 +1,67 @@
+package collect
+
+import "math"
+
+// SetRandom setting a random source of AddSampled and HistogramSampled,
+// f must return a number in [0.0,1.0) and be safe for concurrent use.
+// default is rand.Float64
+func (c *SimpleCollector) SetRandom(f func() float64) {
+	c.randomMu.Lock()
+	defer c.randomMu.Unlock()
+	c.random = f
+}
+
+// sampled return the sample rate clamped to 1, and whether to record a call of the rate
+func (c *SimpleCollector) sampled(rate float64) (float64, bool) {
+	if rate >= 1 {
+		return 1, true
+	}
+	if rate <= 0 {
+		return rate, false
+	}
+	c.randomMu.RLock()
+	random := c.random
+	c.randomMu.RUnlock()
+	return rate, random() < rate
+}
+
+// sampleWeight return round(1/rate) for the sample rate in (0,1], it is clamped to MaxInt64
+func sampleWeight(rate float64) int64 {
+	w := math.Floor(1/rate + 0.5)
+	if w >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(w)
+}
+
+// AddSampled add count for CounterMetrics only a fraction of calls by rate in (0,1].
+// recorded delta is scaled by 1/rate to estimate the total count, rate over 1 is treated as 1
+func (c *SimpleCollector) AddSampled(key string, delta float64, rate float64) {
+	rate, ok := c.sampled(rate)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	key, err := c.add(key, delta/rate, nil)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeCounter, Value: delta, SampleRate: rate})
+	}
+}
+
+// HistogramSampled add metrics for Histogram only a fraction of calls by rate in (0,1].
+// recorded value is weighted by round(1/rate) the same as HistogramN to estimate the total count,
+// rate over 1 is treated as 1
+func (c *SimpleCollector) HistogramSampled(key string, delta float64, rate float64) {
+	rate, ok := c.sampled(rate)
+	if !ok {
+		return
+	}
+	n := sampleWeight(rate)
+	c.mu.Lock()
+	key, err := c.histogramN(key, delta, n)
+	c.mu.Unlock()
+	if err == nil {
+		c.notify(Event{Key: key, Type: TypeHistogram, Value: delta, SampleRate: rate})
+	}
+}
@@ -0,0 +1,116 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sequenceRandom return a random source returns values in order
+func sequenceRandom(values ...float64) func() float64 {
+	i := 0
+	return func() float64 {
+		v := values[i%len(values)]
+		i++
+		return v
+	}
+}
+
+func TestAddSampled(t *testing.T) {
+	cases := []struct {
+		rate   float64
+		random []float64
+		expect []byte
+		events int
+	}{
+		{0.1, []float64{0.05, 0.5, 0.09, 0.1}, []byte(`{"a":20}`), 2},
+		{0.5, []float64{0.6, 0.7, 0.8, 0.9}, nil, 0},
+		{1, []float64{0.99}, []byte(`{"a":4}`), 4},
+		{0, []float64{0}, nil, 0},
+	}
+	for i, tc := range cases {
+		c := NewSimpleCollector()
+		c.SetRandom(sequenceRandom(tc.random...))
+		var events []Event
+		c.AddObserver(ObserverFunc(func(e Event) {
+			events = append(events, e)
+		}))
+		for j := 0; j < 4; j++ {
+			c.AddSampled("a", 1, tc.rate)
+		}
+
+		got, err := c.GetMetrics("a")
+		if tc.expect == nil {
+			if err != ErrNotFoundMetrics {
+				t.Errorf("#%d: want error %v, got %v", i, ErrNotFoundMetrics, err)
+			}
+		} else if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+		if len(events) != tc.events {
+			t.Fatalf("#%d: want %d events, got %d", i, tc.events, len(events))
+		}
+		for _, e := range events {
+			if e.Value != 1 || e.SampleRate != tc.rate {
+				t.Errorf("#%d: want value 1 and rate %v, got %v", i, tc.rate, e)
+			}
+		}
+	}
+}
+
+func TestHistogramSampled(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetRandom(sequenceRandom(0.1, 0.3, 0.2, 0.4))
+	var events []Event
+	c.AddObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+	for _, v := range []float64{1, 2, 3, 4} {
+		c.HistogramSampled("a", v, 0.25)
+	}
+
+	// weighted by 1/rate
+	expect := []byte(`{"a.95percentile":3,"a.avg":2,"a.count":8,"a.max":3,"a.median":3}`)
+	got, err := c.GetMetrics("a")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+	if len(events) != 2 || events[0].SampleRate != 0.25 {
+		t.Errorf("want 2 events with rate 0.25, got %v", events)
+	}
+}
+
+func TestSampledRateClamp(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetRandom(sequenceRandom(0))
+	var events []Event
+	c.AddObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+	c.AddSampled("a", 1, 2)
+	c.HistogramSampled("h", 1, 3)
+	c.HistogramSampled("tiny", 1, 1e-300)
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"a", []byte(`{"a":1}`)},
+		{"h", []byte(`{"h.95percentile":0,"h.avg":1,"h.count":1,"h.max":1,"h.median":1}`)},
+		{"tiny", []byte(`{"tiny.95percentile":1,"tiny.avg":1,"tiny.count":9223372036854776000,"tiny.max":1,"tiny.median":1}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+	if len(events) != 3 || events[0].SampleRate != 1 || events[1].SampleRate != 1 {
+		t.Errorf("want 3 events with clamped rates, got %v", events)
+	}
+}
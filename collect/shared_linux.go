@@ -0,0 +1,348 @@
+package collect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// about shared collector errors
+var (
+	ErrInvalidSharedFile = errors.New("invalid shared metrics file")
+)
+
+// SharedCollector layout of the memory-mapped file, the header is little endian and
+// fields of slots are native endian for atomic operations.
+//
+// header (64 bytes): magic [8]byte, version uint32, slot size uint32, number of slots uint64
+// slot (128 bytes):  state uint32, type uint32, value uint64 (float64 bits), key length uint32, padding, key [104]byte
+//
+// the slot of a key is found by linear probing from the hash of the key, so processes agree on
+// slots without a lock. an empty slot is claimed by CAS of state, and the value is updated atomically.
+// the claimed state has pid of the claimer, so a slot claimed by a died process is found and reset.
+// processes must share the pid namespace
+const (
+	sharedMagic      = "GOMETSHM"
+	sharedVersion    = 1
+	sharedHeaderSize = 64
+	sharedSlotSize   = 128
+
+	sharedSlotState  = 0
+	sharedSlotType   = 4
+	sharedSlotValue  = 8
+	sharedSlotKeyLen = 16
+	sharedSlotKey    = 24
+
+	// SharedMaxKeyLength is maximum length of keys of SharedCollector
+	SharedMaxKeyLength = sharedSlotSize - sharedSlotKey
+)
+
+// states of the slot, the claimed state has pid of the claimer in bits over sharedStatePidShift
+const (
+	sharedStateEmpty uint32 = iota
+	sharedStateClaimed
+	sharedStateReady
+
+	sharedStateMask     = 3
+	sharedStatePidShift = 2
+)
+
+// types of the slot, fixed values in the file instead of MetricType
+const (
+	sharedTypeCounter uint32 = 1
+	sharedTypeGauge   uint32 = 2
+)
+
+// sharedClaimSpins is number of waiting for a claimed slot, the write is dropped after that
+// unless the process claimed the slot is died
+const sharedClaimSpins = 1000
+
+// SharedCollector is implemented Collector backed by a memory-mapped file, it is available on Linux.
+// counters and gauges written by many processes are visible to every process mapped the same file,
+// e.g. workers of a pre-fork server write metrics, and a forwarder process reads them.
+// Histogram, Set and Snapshot are ignored, and keys longer than SharedMaxKeyLength or
+// over the number of slots are ignored
+type SharedCollector struct {
+	file      *os.File
+	data      []byte
+	slots     int
+	precision int
+
+	// offsets is a cache of slot offsets by key in this process
+	offsets map[string]int
+	mu      sync.RWMutex
+}
+
+// NewSharedCollector return SharedCollector mapped the file, the file is created with the number
+// of slots when not exist. otherwise the number of slots of the file is used
+func NewSharedCollector(path string, slots int) (*SharedCollector, error) {
+	if slots <= 0 {
+		return nil, errors.Wrapf(ErrInvalidSharedFile, "slots %d", slots)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	c, err := mapSharedFile(f, slots)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// mapSharedFile initialize the file when empty, and map it. the file lock excludes other
+// processes initializing the same file
+func mapSharedFile(f *os.File, slots int) (*SharedCollector, error) {
+	fd := int(f.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return nil, errors.Wrap(err, "failed to lock shared file")
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat shared file")
+	}
+	size := fi.Size()
+	if size == 0 {
+		header := make([]byte, sharedHeaderSize)
+		copy(header, sharedMagic)
+		binary.LittleEndian.PutUint32(header[8:], sharedVersion)
+		binary.LittleEndian.PutUint32(header[12:], sharedSlotSize)
+		binary.LittleEndian.PutUint64(header[16:], uint64(slots))
+		size = int64(sharedHeaderSize + slots*sharedSlotSize)
+		if err := f.Truncate(size); err != nil {
+			return nil, errors.Wrap(err, "failed to allocate shared file")
+		}
+		if _, err := f.WriteAt(header, 0); err != nil {
+			return nil, errors.Wrap(err, "failed to write shared file header")
+		}
+	}
+
+	header := make([]byte, sharedHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, errors.Wrap(ErrInvalidSharedFile, err.Error())
+	}
+	if string(header[:8]) != sharedMagic ||
+		binary.LittleEndian.Uint32(header[8:]) != sharedVersion ||
+		binary.LittleEndian.Uint32(header[12:]) != sharedSlotSize {
+		return nil, errors.Wrap(ErrInvalidSharedFile, "unknown header")
+	}
+	n := binary.LittleEndian.Uint64(header[16:])
+	if n == 0 || int64(n) > (size-sharedHeaderSize)/sharedSlotSize {
+		return nil, errors.Wrapf(ErrInvalidSharedFile, "slots %d", n)
+	}
+
+	data, err := syscall.Mmap(fd, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to map shared file")
+	}
+	return &SharedCollector{
+		file:      f,
+		data:      data,
+		slots:     int(n),
+		precision: ShortestPrecision,
+		offsets:   make(map[string]int),
+	}, nil
+}
+
+// Close unmap and close the file, the collector must not be used after that
+func (c *SharedCollector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		return nil
+	}
+	if err := syscall.Munmap(c.data); err != nil {
+		return errors.Wrap(err, "failed to unmap shared file")
+	}
+	c.data = nil
+	return c.file.Close()
+}
+
+// SetPrecision setting digits after the decimal point of encoded metrics values
+func (c *SharedCollector) SetPrecision(prec int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.precision = prec
+}
+
+// Add add count to the counter slot
+func (c *SharedCollector) Add(key string, delta float64) {
+	off, ok := c.slot(key, sharedTypeCounter, true)
+	if !ok {
+		return
+	}
+	p := c.uint64At(off + sharedSlotValue)
+	for {
+		old := atomic.LoadUint64(p)
+		if atomic.CompareAndSwapUint64(p, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// Gauge set the value of the gauge slot
+func (c *SharedCollector) Gauge(key string, v float64) {
+	off, ok := c.slot(key, sharedTypeGauge, true)
+	if !ok {
+		return
+	}
+	atomic.StoreUint64(c.uint64At(off+sharedSlotValue), math.Float64bits(v))
+}
+
+// Histogram is not supported by fixed slots, ignored
+func (c *SharedCollector) Histogram(key string, v float64) {}
+
+// Set is not supported by fixed slots, ignored
+func (c *SharedCollector) Set(key string, v string) {}
+
+// Snapshot is not supported by fixed slots, ignored
+func (c *SharedCollector) Snapshot(key string, v []string) {}
+
+// GetMetrics return JSON of the slot, the same format as SimpleCollector
+func (c *SharedCollector) GetMetrics(key string) ([]byte, error) {
+	off, ok := c.slot(key, 0, false)
+	if !ok {
+		return nil, ErrNotFoundMetrics
+	}
+	c.mu.RLock()
+	prec := c.precision
+	c.mu.RUnlock()
+
+	v := math.Float64frombits(atomic.LoadUint64(c.uint64At(off + sharedSlotValue)))
+	return marshalJSONWithOrder(map[string]Data{key: &Float{f: v}}, prec)
+}
+
+// GetMetricsKeys return sorted keys of all ready slots
+func (c *SharedCollector) GetMetricsKeys() []string {
+	res := make([]string, 0)
+	for i := 0; i < c.slots; i++ {
+		off := sharedHeaderSize + i*sharedSlotSize
+		if atomic.LoadUint32(c.uint32At(off+sharedSlotState)) != sharedStateReady {
+			continue
+		}
+		res = append(res, string(c.key(off)))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// slot return offset of the slot of the key, and claim an empty slot when create is true.
+// typ 0 matches any type, and the slot of other type is not matched
+func (c *SharedCollector) slot(key string, typ uint32, create bool) (int, bool) {
+	c.mu.RLock()
+	off, ok := c.offsets[key]
+	c.mu.RUnlock()
+	if ok {
+		return off, typ == 0 || c.typ(off) == typ
+	}
+	if len(key) == 0 || len(key) > SharedMaxKeyLength {
+		return 0, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	start := int(h.Sum64() % uint64(c.slots))
+	for i := 0; i < c.slots; i++ {
+		off := sharedHeaderSize + (start+i)%c.slots*sharedSlotSize
+		state := c.uint32At(off + sharedSlotState)
+		if create && atomic.CompareAndSwapUint32(state, sharedStateEmpty, claimedState(os.Getpid())) {
+			c.claim(off, key, typ)
+			atomic.StoreUint32(state, sharedStateReady)
+		}
+		switch c.waitReady(state) {
+		case sharedStateEmpty:
+			// keys are not found after the first empty slot
+			return 0, false
+		case sharedStateReady:
+		default:
+			// the key may be claimed in the slot, not to split the key into other slots
+			if !c.recover(state) {
+				return 0, false
+			}
+			i--
+			continue
+		}
+		if !bytes.Equal(c.key(off), []byte(key)) {
+			continue
+		}
+		c.mu.Lock()
+		c.offsets[key] = off
+		c.mu.Unlock()
+		return off, typ == 0 || c.typ(off) == typ
+	}
+	return 0, false
+}
+
+// claim write the key and the type to the claimed slot
+func (c *SharedCollector) claim(off int, key string, typ uint32) {
+	atomic.StoreUint32(c.uint32At(off+sharedSlotType), typ)
+	atomic.StoreUint64(c.uint64At(off+sharedSlotValue), 0)
+	copy(c.data[off+sharedSlotKey:off+sharedSlotSize], key)
+	atomic.StoreUint32(c.uint32At(off+sharedSlotKeyLen), uint32(len(key)))
+}
+
+// claimedState return the claimed state by the process
+func claimedState(pid int) uint32 {
+	return uint32(pid)<<sharedStatePidShift | sharedStateClaimed
+}
+
+// waitReady return the state of the slot, wait for the slot claimed by other process.
+// return the claimed state when it is not ready after sharedClaimSpins
+func (c *SharedCollector) waitReady(state *uint32) uint32 {
+	var s uint32
+	for i := 0; i < sharedClaimSpins; i++ {
+		s = atomic.LoadUint32(state)
+		if s&sharedStateMask != sharedStateClaimed {
+			return s
+		}
+		runtime.Gosched()
+	}
+	return s
+}
+
+// recover reset the slot claimed by a died process to empty, and return false when the claimer is alive.
+// the slot claimed by other process at the same time is not reset, the state has other pid
+func (c *SharedCollector) recover(state *uint32) bool {
+	s := atomic.LoadUint32(state)
+	if s&sharedStateMask != sharedStateClaimed {
+		return true
+	}
+	pid := int(s >> sharedStatePidShift)
+	if pid == 0 || syscall.Kill(pid, 0) != syscall.ESRCH {
+		return false
+	}
+	atomic.CompareAndSwapUint32(state, s, sharedStateEmpty)
+	return true
+}
+
+func (c *SharedCollector) typ(off int) uint32 {
+	return atomic.LoadUint32(c.uint32At(off + sharedSlotType))
+}
+
+func (c *SharedCollector) key(off int) []byte {
+	n := int(atomic.LoadUint32(c.uint32At(off + sharedSlotKeyLen)))
+	return c.data[off+sharedSlotKey : off+sharedSlotKey+n]
+}
+
+// uint32At return pointer to the mapped memory, off is aligned in the layout
+func (c *SharedCollector) uint32At(off int) *uint32 {
+	return (*uint32)(unsafe.Pointer(&c.data[off]))
+}
+
+// uint64At return pointer to the mapped memory, off is aligned in the layout
+func (c *SharedCollector) uint64At(off int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&c.data[off]))
+}
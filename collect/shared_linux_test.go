@@ -0,0 +1,239 @@
+package collect
+
+import (
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func newTestSharedCollector(t *testing.T, slots int) (*SharedCollector, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	path := filepath.Join(dir, "metrics")
+	c, err := NewSharedCollector(path, slots)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("want no error, got %v", err)
+	}
+	return c, path
+}
+
+func TestSharedCollector(t *testing.T) {
+	c, path := newTestSharedCollector(t, 8)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer c.Close()
+
+	simple := NewSimpleCollector()
+	for _, sc := range []Collector{c, simple} {
+		sc.Add("requests", 1)
+		sc.Add("requests", 2.5)
+		sc.Gauge("queue", 3)
+		sc.Gauge("queue", -0.5)
+		sc.Add(`errors{code="500"}`, 1)
+	}
+	// ignored
+	c.Gauge("requests", 10)
+	c.Histogram("latency", 1)
+	c.Set("users", "a")
+	c.Snapshot("status", []string{"ok"})
+	c.Add(strings.Repeat("a", SharedMaxKeyLength+1), 1)
+
+	// reader of the other mapping
+	reader, err := NewSharedCollector(path, 1)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer reader.Close()
+	if reader.slots != 8 {
+		t.Errorf("want slots of the file %d, got %d", 8, reader.slots)
+	}
+
+	expect := simple.GetMetricsKeys()
+	for i, sc := range []*SharedCollector{c, reader} {
+		if got := sc.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %v, got %v", i, expect, got)
+		}
+		for _, key := range expect {
+			want, _ := simple.GetMetrics(key)
+			got, err := sc.GetMetrics(key)
+			if err != nil {
+				t.Fatalf("#%d: want no error, got %v", i, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("#%d: want %s, got %s", i, want, got)
+			}
+		}
+		if _, err := sc.GetMetrics("latency"); err != ErrNotFoundMetrics {
+			t.Errorf("#%d: want error %v, got %v", i, ErrNotFoundMetrics, err)
+		}
+	}
+}
+
+func TestSharedCollectorFull(t *testing.T) {
+	c, path := newTestSharedCollector(t, 2)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer c.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Add(key, 1)
+	}
+	if got := c.GetMetricsKeys(); len(got) != 2 {
+		t.Errorf("want 2 keys, got %v", got)
+	}
+}
+
+func TestSharedCollectorInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics")
+	if err := ioutil.WriteFile(path, []byte(strings.Repeat("x", 256)), 0644); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	cases := []struct {
+		path  string
+		slots int
+	}{
+		{path, 8},
+		{filepath.Join(dir, "new"), 0},
+	}
+	for i, tc := range cases {
+		if _, err := NewSharedCollector(tc.path, tc.slots); errors.Cause(err) != ErrInvalidSharedFile {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidSharedFile, err)
+		}
+	}
+}
+
+func TestSharedCollectorClaimedSlot(t *testing.T) {
+	// the process is died
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	cases := []struct {
+		pid    int
+		finish bool
+		expect []byte
+	}{
+		// wait for the claimer, not split the key into the next slot
+		{os.Getpid(), true, []byte(`{"x":2}`)},
+		{cmd.Process.Pid, false, []byte(`{"x":1}`)},
+	}
+	for i, tc := range cases {
+		c, path := newTestSharedCollector(t, 8)
+		h := fnv.New64a()
+		h.Write([]byte("x"))
+		off := sharedHeaderSize + int(h.Sum64()%8)*sharedSlotSize
+		state := c.uint32At(off + sharedSlotState)
+		atomic.StoreUint32(state, claimedState(tc.pid))
+
+		c.Add("x", 1)
+		if tc.finish {
+			if got := c.GetMetricsKeys(); len(got) != 0 {
+				t.Errorf("#%d: want no keys, got %v", i, got)
+			}
+			c.claim(off, "x", sharedTypeCounter)
+			atomic.StoreUint32(state, sharedStateReady)
+			c.Add("x", 2)
+		}
+		if got, expect := c.GetMetricsKeys(), []string{"x"}; !reflect.DeepEqual(got, expect) {
+			t.Errorf("#%d: want %v, got %v", i, expect, got)
+		}
+		if got, _ := c.GetMetrics("x"); !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+		c.Close()
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
+
+func TestSharedCollectorConcurrent(t *testing.T) {
+	c, path := newTestSharedCollector(t, 64)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := NewSharedCollector(path, 64)
+			if err != nil {
+				t.Errorf("want no error, got %v", err)
+				return
+			}
+			defer w.Close()
+			for j := 0; j < 1000; j++ {
+				w.Add("requests", 1)
+				w.Add(string('a'+rune(j%26)), 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	expect := []byte(`{"requests":8000}`)
+	if got, _ := c.GetMetrics("requests"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+	if got := c.GetMetricsKeys(); len(got) != 27 {
+		t.Errorf("want 27 keys, got %v", got)
+	}
+}
+
+// TestSharedCollectorProcesses run workers in other processes of the test binary
+func TestSharedCollectorProcesses(t *testing.T) {
+	c, path := newTestSharedCollector(t, 16)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=TestSharedCollectorWorkerProcess")
+			cmd.Env = append(os.Environ(), "GO_METRICS_SHARED_FILE="+path)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("want no error, got %v: %s", err, out)
+			}
+		}()
+	}
+	wg.Wait()
+
+	expect := []byte(`{"requests":4000}`)
+	if got, _ := c.GetMetrics("requests"); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+// TestSharedCollectorWorkerProcess is not a test, it is a worker of TestSharedCollectorProcesses
+func TestSharedCollectorWorkerProcess(t *testing.T) {
+	path := os.Getenv("GO_METRICS_SHARED_FILE")
+	if path == "" {
+		return
+	}
+	c, err := NewSharedCollector(path, 16)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 1000; i++ {
+		c.Add("requests", 1)
+		c.Gauge("worker", float64(os.Getpid()))
+	}
+}
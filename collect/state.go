@@ -0,0 +1,546 @@
+package collect
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// about state errors
+var (
+	ErrUnsupportedStateVersion = errors.New("unsupported state version")
+	ErrInvalidState            = errors.New("invalid state")
+)
+
+// stateVersion is a version of state format written by SaveState
+const stateVersion = 1
+
+// collectorState is a JSON format of SimpleCollector state
+type collectorState struct {
+	Version int            `json:"version"`
+	Metrics []metricsState `json:"metrics"`
+}
+
+// metricsState is a JSON format of Metrics state
+type metricsState struct {
+	Key     string            `json:"key"`
+	Type    string            `json:"type"`
+	Value   stateFloat        `json:"value,omitempty"`
+	Strings []string          `json:"strings,omitempty"`
+	Updated *time.Time        `json:"updated,omitempty"`
+	Sample  *sampleState      `json:"sample,omitempty"`
+	TopK    *topKState        `json:"topk,omitempty"`
+	Tally   *tallyState       `json:"tally,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Active  string            `json:"active,omitempty"`
+	Expr    string            `json:"expr,omitempty"`
+}
+
+// stateFloat is float64 encoded NaN and ±Inf as strings the same as formatFloat,
+// encoding/json can not encode them
+type stateFloat float64
+
+// MarshalJSON return JSON number, or string of NaN and ±Inf
+func (f stateFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return formatFloat(v, ShortestPrecision), nil
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON read JSON number, or string of NaN and ±Inf
+func (f *stateFloat) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		switch s {
+		case "NaN":
+			*f = stateFloat(math.NaN())
+		case "+Inf":
+			*f = stateFloat(math.Inf(1))
+		case "-Inf":
+			*f = stateFloat(math.Inf(-1))
+		default:
+			return errors.Wrapf(ErrInvalidState, "invalid float %q", s)
+		}
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = stateFloat(v)
+	return nil
+}
+
+// stateFloats is []float64 encoded by stateFloat
+type stateFloats []float64
+
+// MarshalJSON return JSON array of stateFloat
+func (s stateFloats) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	res := make([]stateFloat, len(s))
+	for i, v := range s {
+		res[i] = stateFloat(v)
+	}
+	return json.Marshal(res)
+}
+
+// UnmarshalJSON read JSON array of stateFloat
+func (s *stateFloats) UnmarshalJSON(b []byte) error {
+	var res []stateFloat
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	if res == nil {
+		*s = nil
+		return nil
+	}
+	*s = make(stateFloats, len(res))
+	for i, v := range res {
+		(*s)[i] = float64(v)
+	}
+	return nil
+}
+
+// tallyState is a JSON format of CountMap state
+type tallyState struct {
+	Limit  int                   `json:"limit"`
+	Counts map[string]stateFloat `json:"counts"`
+}
+
+// sampleState is a JSON format of histogram Sample state
+type sampleState struct {
+	Type       string      `json:"type"`
+	Count      int64       `json:"count"`
+	Values     stateFloats `json:"values"`
+	Size       int         `json:"size,omitempty"`
+	Alpha      float64     `json:"alpha,omitempty"`
+	Landmark   *time.Time  `json:"landmark,omitempty"`
+	Priorities stateFloats `json:"priorities,omitempty"`
+	Weights    []int64     `json:"weights,omitempty"`
+	Lowest     float64     `json:"lowest,omitempty"`
+	Highest    float64     `json:"highest,omitempty"`
+	Digits     int         `json:"digits,omitempty"`
+}
+
+// topKState is a JSON format of SpaceSaving state
+type topKState struct {
+	K      int              `json:"k"`
+	Values []topKEntryState `json:"values"`
+}
+
+// topKEntryState is a JSON format of tracked value of SpaceSaving
+type topKEntryState struct {
+	Value string     `json:"value"`
+	Count stateFloat `json:"count"`
+	Err   stateFloat `json:"err"`
+}
+
+// Sample types of sampleState
+const (
+	sampleTypeFloatSlice = "float_slice"
+	sampleTypeExpDecay   = "exp_decay"
+	sampleTypeHDR        = "hdr"
+)
+
+// SaveState write all metrics state as JSON
+func (c *SimpleCollector) SaveState(w io.Writer) error {
+	c.mu.RLock()
+	state := collectorState{
+		Version: stateVersion,
+		Metrics: make([]metricsState, 0, len(c.metrics)),
+	}
+	for _, k := range c.sortedKeys() {
+		ms, err := encodeMetricsState(k, c.metrics[k])
+		if err != nil {
+			c.mu.RUnlock()
+			return err
+		}
+		state.Metrics = append(state.Metrics, ms)
+	}
+	c.mu.RUnlock()
+
+	return json.NewEncoder(w).Encode(state)
+}
+
+// LoadState read metrics state written by SaveState.
+// loaded metrics overwrite metrics of the same key
+func (c *SimpleCollector) LoadState(r io.Reader) error {
+	var state collectorState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return errors.Wrap(ErrInvalidState, err.Error())
+	}
+	if state.Version != stateVersion {
+		return ErrUnsupportedStateVersion
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// keys are applied KeyPolicy, rejected keys are not loaded
+	metrics := make(map[string]Metrics, len(state.Metrics))
+	for _, ms := range state.Metrics {
+		key, ok := c.normalizeKey(ms.Key)
+		if !ok {
+			continue
+		}
+		ms.Key = key
+		m, err := ms.decode()
+		if err != nil {
+			return err
+		}
+		metrics[ms.Key] = m
+	}
+	for k, v := range metrics {
+		switch m := v.(type) {
+		case *DerivedMetrics:
+			m.c = c
+		case *HistogramMetrics:
+			setSampleClock(m.value, c.clock)
+		}
+		c.metrics[k] = v
+	}
+	return nil
+}
+
+// Checkpoint write state to the file atomically
+func (c *SimpleCollector) Checkpoint(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	// remove temporary file when failed
+	defer os.Remove(f.Name())
+
+	if err := c.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RunCheckpoint run Checkpoint() goroutine every interval, and return a channel of checkpoint errors.
+// errors are dropped while the channel is full, and the channel is closed when ctx is done
+func (c *SimpleCollector) RunCheckpoint(ctx context.Context, path string, interval time.Duration) <-chan error {
+	c.mu.RLock()
+	clock := c.clock
+	c.mu.RUnlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		t := clock.NewTicker(interval)
+		for {
+			select {
+			case <-t.Chan():
+				if err := c.Checkpoint(path); err != nil {
+					select {
+					case errCh <- err:
+					default:
+					}
+				}
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+	return errCh
+}
+
+func encodeMetricsState(key string, m Metrics) (metricsState, error) {
+	ms := metricsState{
+		Key:  key,
+		Type: m.GetType().String(),
+	}
+	switch m := m.(type) {
+	case *CounterMetrics:
+		ms.Value = stateFloat(m.value.get())
+	case *GaugeMetrics:
+		v, updated := m.get()
+		ms.Value = stateFloat(v)
+		ms.Updated = &updated
+	case *HistogramMetrics:
+		ss, err := encodeSampleState(m.value)
+		if err != nil {
+			return ms, err
+		}
+		ms.Sample = ss
+	case *SetMetrics:
+		ms.Strings = m.value.keys()
+	case *SnapshotMetrics:
+		m.value.mu.RLock()
+		updated := m.updated
+		m.value.mu.RUnlock()
+		ms.Strings = m.value.keys()
+		ms.Updated = &updated
+	case *TopKMetrics:
+		ms.TopK = encodeTopKState(m.value)
+	case *TallyMetrics:
+		m.value.mu.RLock()
+		ms.Tally = &tallyState{
+			Limit:  m.value.limit,
+			Counts: make(map[string]stateFloat, len(m.value.v)),
+		}
+		for k, v := range m.value.v {
+			ms.Tally.Counts[k] = stateFloat(v)
+		}
+		m.value.mu.RUnlock()
+	case *InfoMetrics:
+		m.mu.RLock()
+		ms.Labels = m.labels
+		updated := m.updated
+		m.mu.RUnlock()
+		ms.Updated = &updated
+	case *StateSetMetrics:
+		m.mu.RLock()
+		ms.Strings = m.states
+		if m.active < len(m.states) {
+			ms.Active = m.states[m.active]
+		}
+		updated := m.updated
+		m.mu.RUnlock()
+		ms.Updated = &updated
+	case *DerivedMetrics:
+		ms.Expr = m.expr
+	default:
+		return ms, errors.Wrapf(ErrInvalidState, "not supported metrics %q", key)
+	}
+	return ms, nil
+}
+
+func encodeSampleState(s Sample) (*sampleState, error) {
+	switch s := s.(type) {
+	case *FloatSlice:
+		values, weights := s.weightedValues()
+		return &sampleState{
+			Type:    sampleTypeFloatSlice,
+			Count:   s.Count(),
+			Values:  values,
+			Weights: weights,
+		}, nil
+	case *ExpDecaySample:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		landmark := s.landmark
+		ss := &sampleState{
+			Type:       sampleTypeExpDecay,
+			Count:      s.count,
+			Values:     make([]float64, 0, s.values.Len()),
+			Size:       s.size,
+			Alpha:      s.alpha,
+			Landmark:   &landmark,
+			Priorities: make([]float64, 0, s.values.Len()),
+		}
+		for _, v := range *s.values {
+			ss.Values = append(ss.Values, v.value)
+			ss.Priorities = append(ss.Priorities, v.priority)
+		}
+		return ss, nil
+	case *HDRSample:
+		values, weights := s.WeightedValues()
+		return &sampleState{
+			Type:    sampleTypeHDR,
+			Count:   s.Count(),
+			Values:  values,
+			Weights: weights,
+			Lowest:  s.lowest,
+			Highest: s.highest,
+			Digits:  s.digits,
+		}, nil
+	default:
+		return nil, errors.Wrap(ErrInvalidState, "not supported sample")
+	}
+}
+
+func encodeTopKState(s *SpaceSaving) *topKState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts := &topKState{
+		K:      s.k,
+		Values: make([]topKEntryState, 0, len(s.heap)),
+	}
+	for _, e := range s.heap {
+		ts.Values = append(ts.Values, topKEntryState{Value: e.value, Count: stateFloat(e.count), Err: stateFloat(e.err)})
+	}
+	return ts
+}
+
+func (ms metricsState) decode() (Metrics, error) {
+	switch ms.Type {
+	case TypeCounter.String():
+		return &CounterMetrics{
+			key:   ms.Key,
+			value: &Float{f: float64(ms.Value)},
+		}, nil
+	case TypeGauge.String():
+		m := &GaugeMetrics{
+			key:   ms.Key,
+			value: &Float{f: float64(ms.Value)},
+		}
+		if ms.Updated != nil {
+			m.updated = *ms.Updated
+		}
+		return m, nil
+	case TypeHistogram.String():
+		if ms.Sample == nil {
+			return nil, errors.Wrapf(ErrInvalidState, "missing sample of %q", ms.Key)
+		}
+		s, err := ms.Sample.decode()
+		if err != nil {
+			return nil, err
+		}
+		return &HistogramMetrics{
+			key:   ms.Key,
+			value: s,
+		}, nil
+	case TypeSet.String():
+		return &SetMetrics{
+			key:   ms.Key,
+			value: newMap(ms.Strings),
+		}, nil
+	case TypeSnapshot.String():
+		m := &SnapshotMetrics{
+			key:   ms.Key,
+			value: newMap(ms.Strings),
+		}
+		if ms.Updated != nil {
+			m.updated = *ms.Updated
+		}
+		return m, nil
+	case TypeTopK.String():
+		if ms.TopK == nil {
+			return nil, errors.Wrapf(ErrInvalidState, "missing topk of %q", ms.Key)
+		}
+		s := NewSpaceSaving(ms.TopK.K)
+		for _, e := range ms.TopK.Values {
+			s.add(e.Value, float64(e.Count), float64(e.Err))
+		}
+		return &TopKMetrics{
+			key:   ms.Key,
+			value: s,
+		}, nil
+	case TypeTally.String():
+		if ms.Tally == nil {
+			return nil, errors.Wrapf(ErrInvalidState, "missing tally of %q", ms.Key)
+		}
+		if ms.Tally.Limit <= 0 {
+			return nil, errors.Wrapf(ErrInvalidState, "limit %d of tally %q", ms.Tally.Limit, ms.Key)
+		}
+		m := newCountMap(ms.Tally.Limit)
+		for k, v := range ms.Tally.Counts {
+			m.v[k] = float64(v)
+		}
+		return &TallyMetrics{
+			key:   ms.Key,
+			value: m,
+		}, nil
+	case TypeInfo.String():
+		m := &InfoMetrics{
+			key:    ms.Key,
+			labels: ms.Labels,
+		}
+		if ms.Updated != nil {
+			m.updated = *ms.Updated
+		}
+		return m, nil
+	case TypeStateSet.String():
+		m := &StateSetMetrics{
+			key:    ms.Key,
+			states: ms.Strings,
+		}
+		found := len(ms.Strings) == 0
+		for i, s := range ms.Strings {
+			if s == ms.Active {
+				m.active = i
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Wrapf(ErrInvalidState, "unknown active state of %q", ms.Key)
+		}
+		if ms.Updated != nil {
+			m.updated = *ms.Updated
+		}
+		return m, nil
+	case TypeDerived.String():
+		m := newDerivedMetrics(ms.Key, ms.Expr, nil)
+		if m == nil {
+			return nil, errors.Wrapf(ErrInvalidState, "invalid expression %q", ms.Expr)
+		}
+		return m, nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidState, "unknown metric type %q", ms.Type)
+	}
+}
+
+func (ss *sampleState) decode() (Sample, error) {
+	switch ss.Type {
+	case sampleTypeFloatSlice:
+		if len(ss.Weights) > 0 && len(ss.Weights) != len(ss.Values) {
+			return nil, errors.Wrap(ErrInvalidState, "broken float_slice sample")
+		}
+		v := make([]float64, len(ss.Values))
+		copy(v, ss.Values)
+		s := &FloatSlice{v: v}
+		if len(ss.Weights) > 0 {
+			s.w = make([]int64, len(ss.Weights))
+			copy(s.w, ss.Weights)
+		}
+		return s, nil
+	case sampleTypeExpDecay:
+		if len(ss.Values) != len(ss.Priorities) || ss.Landmark == nil || ss.Size < 1 || len(ss.Values) > ss.Size {
+			return nil, errors.Wrap(ErrInvalidState, "broken exp_decay sample")
+		}
+		s := NewExpDecaySample(ss.Size, ss.Alpha)
+		s.count = ss.Count
+		s.landmark = *ss.Landmark
+		s.nextRescale = s.landmark.Add(rescaleThreshold)
+		for i, v := range ss.Values {
+			heap.Push(s.values, prioritized{value: v, priority: ss.Priorities[i]})
+		}
+		return s, nil
+	case sampleTypeHDR:
+		if len(ss.Values) != len(ss.Weights) {
+			return nil, errors.Wrap(ErrInvalidState, "broken hdr sample")
+		}
+		s, err := NewHDRSample(ss.Lowest, ss.Highest, ss.Digits)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidState, err.Error())
+		}
+		// representative values are counted in the same buckets
+		for i, v := range ss.Values {
+			s.UpdateN(v, ss.Weights[i])
+		}
+		return s, nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidState, "unknown sample type %q", ss.Type)
+	}
+}
+
+func newMap(values []string) *Map {
+	m := &Map{
+		v: make(map[string]struct{}, len(values)),
+	}
+	for _, v := range values {
+		m.v[v] = struct{}{}
+	}
+	return m
+}
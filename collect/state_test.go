@@ -0,0 +1,238 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func createMixedCollector(t *testing.T) *SimpleCollector {
+	c := NewSimpleCollector()
+	c.Add("c", 2)
+	c.Gauge("g", 5)
+	c.Histogram("h", 10.5)
+	c.Histogram("h", 10)
+	if err := c.RegisterHistogram("eh", NewExpDecaySample(10, 0.015)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	c.Histogram("eh", 1)
+	c.Histogram("eh", 2)
+	c.Set("s", "a")
+	c.Set("s", "b")
+	c.Snapshot("ss", []string{"b", "c"})
+	c.TopK("tk", "a")
+	c.TopK("tk", "b")
+	c.TopK("tk", "a")
+	c.Tally("tl", "200")
+	c.Tally("tl", "500")
+	c.Tally("tl", "200")
+	c.Info("info", map[string]string{"version": "1.0.0"})
+	if err := c.RegisterStateSet("st", "closed", "open"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.SetState("st", "open"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	return c
+}
+
+func TestSaveAndLoadState(t *testing.T) {
+	src := createMixedCollector(t)
+	var buf bytes.Buffer
+	if err := src.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	dst := NewSimpleCollector()
+	dst.Add("c", 100)
+	dst.Add("other", 1)
+	if err := dst.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	// expect: keys are restored and existing keys are kept
+	expectKeys := []string{"c", "eh", "g", "h", "info", "other", "s", "ss", "st", "tk", "tl"}
+	if got := dst.GetMetricsKeys(); !reflect.DeepEqual(got, expectKeys) {
+		t.Fatalf("want keys %v, got %v", expectKeys, got)
+	}
+	for _, k := range src.GetMetricsKeys() {
+		expect, err := src.GetMetrics(k)
+		if err != nil {
+			t.Fatalf("%s: want no error, got %v", k, err)
+		}
+		got, err := dst.GetMetrics(k)
+		if err != nil {
+			t.Fatalf("%s: want no error, got %v", k, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("%s: want %s, got %s", k, expect, got)
+		}
+		if src.metrics[k].GetType() != dst.metrics[k].GetType() {
+			t.Errorf("%s: want type %s, got %s", k, src.metrics[k].GetType(), dst.metrics[k].GetType())
+		}
+	}
+	if _, ok := dst.metrics["eh"].(*HistogramMetrics).value.(*ExpDecaySample); !ok {
+		t.Errorf("want restored *ExpDecaySample")
+	}
+}
+
+func TestLoadStateError(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect error
+	}{
+		{`{"version":2,"metrics":[]}`, ErrUnsupportedStateVersion},
+		{`{"version":1,"metrics":[{"key":"a","type":"unknown"}]}`, ErrInvalidState},
+		{`{"version":1,"metrics":[{"key":"a","type":"histogram"}]}`, ErrInvalidState},
+		{`{"version":1,"metrics":[{"key":"a","type":"histogram","sample":{"type":"exp_decay","count":0,"values":[],"size":0,"alpha":0.015,"landmark":"2017-07-14T02:40:00Z"}}]}`, ErrInvalidState},
+		{`{"version":1,"metrics":[{"key":"a","type":"tally","tally":{"limit":0,"counts":{"x":1}}}]}`, ErrInvalidState},
+		{`{`, ErrInvalidState},
+	}
+	for i, c := range cases {
+		sc := NewSimpleCollector()
+		err := sc.LoadState(strings.NewReader(c.input))
+		if errors.Cause(err) != c.expect {
+			t.Errorf("#%d: want error %v, got %v", i, c.expect, err)
+		}
+		if keys := sc.GetMetricsKeys(); len(keys) != 0 {
+			t.Errorf("#%d: want no keys, got %v", i, keys)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	c := createMixedCollector(t)
+	if err := c.Checkpoint(path); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer f.Close()
+	restored := NewSimpleCollector()
+	if err := restored.LoadState(f); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect, got := c.GetMetricsKeys(), restored.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want keys %v, got %v", expect, got)
+	}
+
+	// expect: temporary files are not left
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("want 1 file, got %d", len(files))
+	}
+}
+
+func TestRunCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	c := createMixedCollector(t)
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	c.SetClock(clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := c.RunCheckpoint(ctx, path, time.Second)
+	clock.WaitTickers(1)
+	clock.mu.Lock()
+	ticker := clock.tickers[0]
+	clock.mu.Unlock()
+
+	// the tick is received, and the goroutine exits after the checkpoint
+	clock.Advance(time.Second)
+	for len(ticker.c) != 0 {
+		runtime.Gosched()
+	}
+	cancel()
+	for err := range errCh {
+		t.Errorf("want no error, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("want checkpoint file %s, got %v", path, err)
+	}
+}
+
+func TestSaveAndLoadNonFiniteState(t *testing.T) {
+	src := NewSimpleCollector()
+	src.Add("c", math.Inf(1))
+	src.Gauge("g", math.NaN())
+	src.Histogram("h", math.Inf(-1))
+	src.Histogram("h", 1)
+	src.Histogram("h", math.Inf(1))
+	if err := src.RegisterHistogram("eh", NewExpDecaySample(10, 0.015)); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	src.Histogram("eh", math.NaN())
+	src.Tally("tl", "a")
+
+	var buf bytes.Buffer
+	if err := src.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), `"value":"+Inf"`) || !strings.Contains(buf.String(), `"value":"NaN"`) {
+		t.Errorf("want non-finite values as strings, got %s", buf.String())
+	}
+	dst := NewSimpleCollector()
+	if err := dst.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	for _, k := range src.GetMetricsKeys() {
+		expect, _ := src.GetMetrics(k)
+		got, err := dst.GetMetrics(k)
+		if err != nil {
+			t.Fatalf("want no error, got %v", err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("want %s, got %s", expect, got)
+		}
+	}
+
+	input := `{"version":1,"metrics":[{"key":"c","type":"counter","value":"Infinity"}]}`
+	if err := dst.LoadState(strings.NewReader(input)); errors.Cause(err) != ErrInvalidState {
+		t.Errorf("want error %v, got %v", ErrInvalidState, err)
+	}
+}
+
+func TestRunCheckpointError(t *testing.T) {
+	c := NewSimpleCollector()
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	c.SetClock(clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := c.RunCheckpoint(ctx, filepath.Join(os.TempDir(), "not-exist-dir", "state.json"), time.Second)
+	clock.WaitTickers(1)
+
+	clock.Advance(time.Second)
+	if err := <-errCh; err == nil {
+		t.Errorf("want checkpoint error, got nil")
+	}
+	cancel()
+	for range errCh {
+	}
+}
@@ -0,0 +1,230 @@
+package collect
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultSubscribeBuffer is the buffer size of subscriptions when not specified
+const defaultSubscribeBuffer = 64
+
+// DropPolicy decides which update is dropped when the buffer of a subscription is full
+type DropPolicy int
+
+// drop policies
+const (
+	// DropNewest discard the new update
+	DropNewest DropPolicy = iota
+	// DropOldest discard the oldest buffered update, and keep the new update
+	DropOldest
+)
+
+// Update is a changed metrics sent to subscribers
+type Update struct {
+	Key  string
+	Type MetricType
+	// Value is aggregated values of the key, the same as GetMetrics
+	Value []byte
+}
+
+// SubscribeFilter selects updates and setting the subscription
+type SubscribeFilter struct {
+	// Keys are subscribed keys, names match their labeled keys. empty means all keys
+	Keys []string
+	// Types are subscribed metric types, empty means all types
+	Types []MetricType
+	// Interval coalesces updates of the same key, and send the latest value every interval.
+	// 0 means sending soon after writes, writes of the same key are coalesced while the update is built
+	Interval time.Duration
+	// Buffer is the size of the channel, default is 64
+	Buffer int
+	// Drop is the policy when the channel is full
+	Drop DropPolicy
+}
+
+// match return whether the filter selects the event
+func (f *SubscribeFilter) match(e Event) bool {
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Keys) == 0 {
+		return true
+	}
+	name, _ := splitLabeledKey(e.Key)
+	for _, k := range f.Keys {
+		if k == e.Key || k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subscription is a registered subscriber, it is an Observer of the collector
+type subscription struct {
+	c      *SimpleCollector
+	filter SubscribeFilter
+	ch     chan Update
+	remove func()
+
+	// dirty are coalesced keys and their types until the next flush, and order is the keys in order of writes
+	dirty  map[string]MetricType
+	order  []string
+	wake   chan struct{}
+	done   chan struct{}
+	closed bool
+	mu     sync.Mutex
+}
+
+// Subscribe return a channel of updates selected by the filter, updates are built and sent by
+// a goroutine of the subscription after writes of the collector. the channel is closed by Unsubscribe.
+// updates are dropped by the policy of the filter when the channel is full, so slow subscribers
+// do not block writes
+func (c *SimpleCollector) Subscribe(filter SubscribeFilter) <-chan Update {
+	if filter.Buffer <= 0 {
+		filter.Buffer = defaultSubscribeBuffer
+	}
+	s := &subscription{
+		c:      c,
+		filter: filter,
+		ch:     make(chan Update, filter.Buffer),
+		dirty:  make(map[string]MetricType),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	var ticker Ticker
+	if filter.Interval > 0 {
+		c.mu.RLock()
+		ticker = c.clock.NewTicker(filter.Interval)
+		c.mu.RUnlock()
+	}
+	go s.run(ticker)
+	s.remove = c.AddObserver(s)
+
+	c.observerMu.Lock()
+	defer c.observerMu.Unlock()
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[<-chan Update]*subscription)
+	}
+	c.subscriptions[s.ch] = s
+	return s.ch
+}
+
+// Unsubscribe stop the subscription and close the channel, unknown channels are ignored
+func (c *SimpleCollector) Unsubscribe(ch <-chan Update) {
+	c.observerMu.Lock()
+	s, ok := c.subscriptions[ch]
+	delete(c.subscriptions, ch)
+	c.observerMu.Unlock()
+	if !ok {
+		return
+	}
+
+	s.remove()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.done)
+	close(s.ch)
+}
+
+// Observe keep the key of the event until the next flush, values are not aggregated on the write path
+func (s *subscription) Observe(e Event) {
+	if !s.filter.match(e) {
+		return
+	}
+	s.mu.Lock()
+	if _, ok := s.dirty[e.Key]; !ok {
+		s.order = append(s.order, e.Key)
+	}
+	s.dirty[e.Key] = e.Type
+	s.mu.Unlock()
+	if s.filter.Interval > 0 {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run send coalesced updates every tick, or after writes when ticker is nil, until unsubscribed
+func (s *subscription) run(ticker Ticker) {
+	var tick <-chan time.Time
+	if ticker != nil {
+		defer ticker.Stop()
+		tick = ticker.Chan()
+	}
+	for {
+		select {
+		case <-tick:
+			s.flush()
+		case <-s.wake:
+			s.flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// flush send the latest values of coalesced keys in order of writes
+func (s *subscription) flush() {
+	s.mu.Lock()
+	dirty, order := s.dirty, s.order
+	s.dirty = make(map[string]MetricType)
+	s.order = nil
+	s.mu.Unlock()
+
+	updates := make([]Update, 0, len(order))
+	for _, k := range order {
+		if u, ok := s.update(k, dirty[k]); ok {
+			updates = append(updates, u)
+		}
+	}
+	s.send(updates)
+}
+
+// update return Update of aggregated values of the key, false when the key is deleted
+func (s *subscription) update(key string, typ MetricType) (Update, bool) {
+	v, err := s.c.GetMetrics(key)
+	if err != nil {
+		return Update{}, false
+	}
+	return Update{Key: key, Type: typ, Value: v}, true
+}
+
+// send send updates without blocking, drop updates by the policy when the channel is full
+func (s *subscription) send(updates []Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for _, u := range updates {
+		select {
+		case s.ch <- u:
+			continue
+		default:
+		}
+		if s.filter.Drop != DropOldest {
+			continue
+		}
+		// the subscriber may receive it at the same time
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- u:
+		default:
+		}
+	}
+}
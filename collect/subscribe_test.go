@@ -0,0 +1,159 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// receive return all buffered updates
+func receive(ch <-chan Update) []Update {
+	res := make([]Update, 0)
+	for {
+		select {
+		case u, ok := <-ch:
+			if !ok {
+				return res
+			}
+			res = append(res, u)
+		default:
+			return res
+		}
+	}
+}
+
+// wait return the next update, or fail when no update is sent
+func wait(t *testing.T, ch <-chan Update) Update {
+	select {
+	case u := <-ch:
+		return u
+	case <-time.After(time.Second):
+		t.Fatalf("want an update, got nothing")
+	}
+	return Update{}
+}
+
+func TestSubscribe(t *testing.T) {
+	c := NewSimpleCollector()
+	all := c.Subscribe(SubscribeFilter{})
+	filtered := c.Subscribe(SubscribeFilter{
+		Keys:  []string{"requests", "queue"},
+		Types: []MetricType{TypeCounter},
+	})
+
+	cases := []struct {
+		write    func()
+		expect   Update
+		filtered bool
+	}{
+		{func() { c.Add("requests", 1) }, Update{Key: "requests", Type: TypeCounter, Value: []byte(`{"requests":1}`)}, true},
+		{func() { c.Counter(`requests{code="200"}`).Inc() }, Update{Key: `requests{code="200"}`, Type: TypeCounter, Value: []byte(`{"requests{code=\"200\"}":1}`)}, true},
+		{func() { c.Gauge("queue", 3) }, Update{Key: "queue", Type: TypeGauge, Value: []byte(`{"queue":3}`)}, false},
+		{func() { c.Histogram("latency", 1) }, Update{Key: "latency", Type: TypeHistogram, Value: []byte(`{"latency.95percentile":0,"latency.avg":1,"latency.count":1,"latency.max":1,"latency.median":1}`)}, false},
+		{func() { c.Histogram("latency", 3) }, Update{Key: "latency", Type: TypeHistogram, Value: []byte(`{"latency.95percentile":2.9,"latency.avg":2,"latency.count":2,"latency.max":3,"latency.median":3}`)}, false},
+		{func() { c.Add("requests", 1) }, Update{Key: "requests", Type: TypeCounter, Value: []byte(`{"requests":2}`)}, true},
+	}
+	for i, tc := range cases {
+		tc.write()
+		if got := wait(t, all); !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %v, got %v", i, tc.expect, got)
+		}
+		if !tc.filtered {
+			continue
+		}
+		// unmatched writes are not sent before it
+		if got := wait(t, filtered); !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %v, got %v", i, tc.expect, got)
+		}
+	}
+
+	// closed, and no more updates
+	c.Unsubscribe(all)
+	c.Unsubscribe(all)
+	c.Add("requests", 1)
+	if _, ok := <-all; ok {
+		t.Errorf("want closed channel")
+	}
+	expect := Update{Key: "requests", Type: TypeCounter, Value: []byte(`{"requests":3}`)}
+	if got := wait(t, filtered); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+}
+
+func TestSubscribeCoalesce(t *testing.T) {
+	c := NewSimpleCollector()
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	c.SetClock(clock)
+	ch := c.Subscribe(SubscribeFilter{Interval: time.Second})
+	clock.WaitTickers(1)
+
+	c.Add("requests", 1)
+	c.Add("requests", 2)
+	c.Gauge("queue", 1)
+	c.Gauge("queue", 5)
+	if got := receive(ch); len(got) != 0 {
+		t.Errorf("want no updates until the interval, got %v", got)
+	}
+
+	clock.Advance(time.Second)
+	expect := []Update{
+		{Key: "requests", Type: TypeCounter, Value: []byte(`{"requests":3}`)},
+		{Key: "queue", Type: TypeGauge, Value: []byte(`{"queue":5}`)},
+	}
+	got := []Update{<-ch, <-ch}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+
+	// no updates without writes
+	clock.Advance(time.Second)
+	c.Unsubscribe(ch)
+	clock.WaitTickers(0)
+	if got := receive(ch); len(got) != 0 {
+		t.Errorf("want no updates, got %v", got)
+	}
+}
+
+func TestSubscribeDropPolicy(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetClock(NewFakeClock(time.Unix(1500000000, 0)))
+	newest := c.Subscribe(SubscribeFilter{Interval: time.Hour, Buffer: 2, Drop: DropNewest})
+	oldest := c.Subscribe(SubscribeFilter{Interval: time.Hour, Buffer: 2, Drop: DropOldest})
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		c.Add(k, 1)
+	}
+
+	cases := []struct {
+		ch     <-chan Update
+		expect []string
+	}{
+		{newest, []string{"a", "b"}},
+		{oldest, []string{"d", "e"}},
+	}
+	for i, tc := range cases {
+		c.subscriptions[tc.ch].flush()
+		got := make([]string, 0)
+		for _, u := range receive(tc.ch) {
+			got = append(got, u.Key)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %v, got %v", i, tc.expect, got)
+		}
+	}
+}
+
+func TestSubscribeConcurrent(t *testing.T) {
+	c := NewSimpleCollector()
+	ch := c.Subscribe(SubscribeFilter{Buffer: 1, Drop: DropOldest})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range ch {
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		c.Add("requests", 1)
+	}
+	c.Unsubscribe(ch)
+	<-done
+}
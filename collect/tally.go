@@ -0,0 +1,182 @@
+package collect
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// about tally errors
+var (
+	ErrInvalidTally = errors.New("invalid limit of tally")
+)
+
+// DefaultTallyLimit is maximum number of distinct members of Tally when not registered
+const DefaultTallyLimit = 100
+
+// TallyOverflowMember is a member counted instead of members over the limit
+const TallyOverflowMember = "__other__"
+
+// TallyMetrics is implemented Metrics for Tally
+type TallyMetrics struct {
+	key   string
+	value *CountMap
+}
+
+// Aggregate return counts per member
+func (m *TallyMetrics) Aggregate() map[string]Data {
+	m.value.mu.RLock()
+	defer m.value.mu.RUnlock()
+	v := make(map[string]float64, len(m.value.v))
+	for k, c := range m.value.v {
+		v[k] = c
+	}
+	return map[string]Data{
+		m.key: &FloatMap{v: v},
+	}
+}
+
+// GetType return MetricType
+func (m *TallyMetrics) GetType() MetricType {
+	return TypeTally
+}
+
+// Merge add counts of other TallyMetrics
+func (m *TallyMetrics) Merge(other Metrics) error {
+	o, ok := other.(*TallyMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	o.value.mu.RLock()
+	counts := make(map[string]float64, len(o.value.v))
+	for k, c := range o.value.v {
+		counts[k] = c
+	}
+	o.value.mu.RUnlock()
+
+	for k, c := range counts {
+		m.value.add(k, c)
+	}
+	return nil
+}
+
+// Reset remove all members
+func (m *TallyMetrics) Reset() {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.v = make(map[string]float64)
+}
+
+// CountMap is used by collect metrics, counts per member with a limit of distinct members
+type CountMap struct {
+	v     map[string]float64
+	limit int
+	mu    sync.RWMutex
+}
+
+// newCountMap return new CountMap
+func newCountMap(limit int) *CountMap {
+	return &CountMap{
+		v:     make(map[string]float64),
+		limit: limit,
+	}
+}
+
+// add count a member, members over the limit are counted as TallyOverflowMember
+func (m *CountMap) add(member string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.v[member]; !ok && len(m.v) >= m.limit {
+		member = TallyOverflowMember
+	}
+	m.v[member] += delta
+}
+
+// FloatMap is implemented Data
+type FloatMap struct {
+	v map[string]float64
+}
+
+// MarshalJSON return specific encoded json sorted by keys
+func (m *FloatMap) MarshalJSON() ([]byte, error) {
+	return m.format(ShortestPrecision), nil
+}
+
+// format return encoded json with prec digits after the decimal point
+func (m *FloatMap) format(prec int) []byte {
+	keys := make([]string, 0, len(m.v))
+	for k := range m.v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(quoteJSON(k))
+		buf.WriteByte(':')
+		buf.Write(formatFloat(m.v[k], prec))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// Tally count a member for TallyMetrics, keeps DefaultTallyLimit members when not registered
+func (c *SimpleCollector) Tally(key string, member string) {
+	if key, err := c.tally(key, member); err == nil {
+		c.notify(Event{Key: key, Type: TypeTally, Values: []string{member}})
+	}
+}
+
+func (c *SimpleCollector) tally(key string, member string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return "", ErrInvalidKey
+	}
+
+	// add key
+	if _, dup := c.metrics[key]; !dup {
+		c.metrics[key] = &TallyMetrics{
+			key:   key,
+			value: newCountMap(DefaultTallyLimit),
+		}
+	}
+
+	// add member, ignore otherwise
+	v, ok := c.metrics[key].(*TallyMetrics)
+	if !ok {
+		return "", ErrMismatchMetricType
+	}
+	v.value.add(member, 1)
+	return key, nil
+}
+
+// RegisterTally add TallyMetrics keeps limit distinct members, return ErrInvalidTally when limit is not positive.
+// members over the limit are counted as TallyOverflowMember
+func (c *SimpleCollector) RegisterTally(key string, limit int) error {
+	if limit <= 0 {
+		return errors.Wrapf(ErrInvalidTally, "limit %d", limit)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+
+	if _, dup := c.metrics[key]; dup {
+		return ErrAlreadyExistMetrics
+	}
+	c.metrics[key] = &TallyMetrics{
+		key:   key,
+		value: newCountMap(limit),
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package collect
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTally(t *testing.T) {
+	c := NewSimpleCollector()
+	for _, v := range []string{"200", "500", "200", "404"} {
+		c.Tally("a", v)
+	}
+	if err := c.RegisterTally("b", 2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.RegisterTally("b", 2); err != ErrAlreadyExistMetrics {
+		t.Fatalf("want error %v, got %v", ErrAlreadyExistMetrics, err)
+	}
+	for _, v := range []string{"200", "500", "200", "404", "301"} {
+		c.Tally("b", v)
+	}
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{"a", []byte(`{"a":{"200":2,"404":1,"500":1}}`)},
+		{"b", []byte(`{"b":{"200":2,"500":1,"__other__":2}}`)},
+	}
+	for i, tc := range cases {
+		if got := c.metrics[tc.key].GetType(); got != TypeTally {
+			t.Fatalf("#%d: want type %s, got %s", i, TypeTally, got)
+		}
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+}
+
+func TestMergeTally(t *testing.T) {
+	c1 := NewSimpleCollector()
+	c1.Tally("a", "200")
+	c1.Tally("a", "500")
+	c2 := NewSimpleCollector()
+	c2.Tally("a", "200")
+	c2.Tally("a", "404")
+	if err := c1.Merge(c2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+
+	expect := []byte(`{"a":{"200":2,"404":1,"500":1}}`)
+	got, err := c1.GetMetrics("a")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestRegisterTallyInvalid(t *testing.T) {
+	c := NewSimpleCollector()
+	for i, limit := range []int{0, -1} {
+		if err := c.RegisterTally("t", limit); errors.Cause(err) != ErrInvalidTally {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidTally, err)
+		}
+	}
+	if keys := c.GetMetricsKeys(); len(keys) != 0 {
+		t.Errorf("want no keys, got %v", keys)
+	}
+}
@@ -0,0 +1,294 @@
+package collect
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// about topk errors
+var (
+	ErrInvalidTopK = errors.New("invalid number of topk")
+)
+
+// DefaultTopK is number of tracked values of TopK when not registered
+const DefaultTopK = 10
+
+// TopKMetrics is implemented Metrics for TopK
+type TopKMetrics struct {
+	key   string
+	value *SpaceSaving
+}
+
+// Aggregate return ranked values and estimated counts
+func (m *TopKMetrics) Aggregate() map[string]Data {
+	return map[string]Data{
+		m.key: m.value.ranking(),
+	}
+}
+
+// GetType return MetricType
+func (m *TopKMetrics) GetType() MetricType {
+	return TypeTopK
+}
+
+// Merge combine values of other TopKMetrics
+func (m *TopKMetrics) Merge(other Metrics) error {
+	o, ok := other.(*TopKMetrics)
+	if !ok {
+		return ErrMismatchMetricType
+	}
+	m.value.merge(o.value)
+	return nil
+}
+
+// Reset remove all values
+func (m *TopKMetrics) Reset() {
+	m.value.mu.Lock()
+	defer m.value.mu.Unlock()
+	m.value.reset()
+}
+
+// SpaceSaving is a summary of the most frequent values, using Space-Saving algorithm.
+// estimated counts are overestimated at most by the minimum count of tracked values.
+// see https://www.cs.ucsb.edu/research/tech-reports/2005-23
+type SpaceSaving struct {
+	k       int
+	entries map[string]*topKEntry
+	heap    topKHeap
+	mu      sync.RWMutex
+}
+
+// NewSpaceSaving return new SpaceSaving tracked k values, k less than 1 is treated as 1
+func NewSpaceSaving(k int) *SpaceSaving {
+	if k < 1 {
+		k = 1
+	}
+	s := &SpaceSaving{k: k}
+	s.reset()
+	return s
+}
+
+func (s *SpaceSaving) reset() {
+	s.entries = make(map[string]*topKEntry, s.k)
+	s.heap = make(topKHeap, 0, s.k)
+}
+
+// Add count a value
+func (s *SpaceSaving) Add(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(v, 1, 0)
+}
+
+// add count a value with delta, expect to be called with lock
+func (s *SpaceSaving) add(v string, delta, errDelta float64) {
+	if e, ok := s.entries[v]; ok {
+		e.count += delta
+		e.err += errDelta
+		heap.Fix(&s.heap, e.index)
+		return
+	}
+	if len(s.heap) < s.k {
+		e := &topKEntry{value: v, count: delta, err: errDelta}
+		s.entries[v] = e
+		heap.Push(&s.heap, e)
+		return
+	}
+	if s.k <= 0 {
+		return
+	}
+
+	// replace the minimum, new value inherits its count as error
+	min := s.heap[0]
+	delete(s.entries, min.value)
+	min.value = v
+	min.err = min.count + errDelta
+	min.count += delta
+	s.entries[v] = min
+	heap.Fix(&s.heap, 0)
+}
+
+// minCount return the minimum count when all slots are used, otherwise 0
+func (s *SpaceSaving) minCount() float64 {
+	if len(s.heap) < s.k || len(s.heap) == 0 {
+		return 0
+	}
+	return s.heap[0].count
+}
+
+// merge combine other summary. counts of values not tracked in the other are
+// estimated by the minimum count of the other
+func (s *SpaceSaving) merge(other *SpaceSaving) {
+	other.mu.RLock()
+	items := make([]topKEntry, 0, len(other.heap))
+	for _, e := range other.heap {
+		items = append(items, *e)
+	}
+	otherMin := other.minCount()
+	other.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	selfMin := s.minCount()
+	merged := make(map[string]topKEntry, len(s.entries)+len(items))
+	for v, e := range s.entries {
+		merged[v] = topKEntry{value: v, count: e.count + otherMin, err: e.err + otherMin}
+	}
+	for _, e := range items {
+		if m, ok := merged[e.value]; ok {
+			m.count += e.count - otherMin
+			m.err += e.err - otherMin
+			merged[e.value] = m
+			continue
+		}
+		merged[e.value] = topKEntry{value: e.value, count: e.count + selfMin, err: e.err + selfMin}
+	}
+
+	list := make([]topKEntry, 0, len(merged))
+	for _, e := range merged {
+		list = append(list, e)
+	}
+	sortTopKEntries(list)
+	if len(list) > s.k {
+		list = list[:s.k]
+	}
+	s.reset()
+	for _, e := range list {
+		s.add(e.value, e.count, e.err)
+	}
+}
+
+// ranking return tracked values ordered by estimated counts
+func (s *SpaceSaving) ranking() *TopKList {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]topKEntry, 0, len(s.heap))
+	for _, e := range s.heap {
+		list = append(list, *e)
+	}
+	sortTopKEntries(list)
+	return &TopKList{v: list}
+}
+
+// sortTopKEntries sort by count desc, and value asc
+func sortTopKEntries(list []topKEntry) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		return list[i].value < list[j].value
+	})
+}
+
+// TopKList is implemented Data
+type TopKList struct {
+	v []topKEntry
+}
+
+// MarshalJSON return specific encoded json
+func (l *TopKList) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, e := range l.v {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"value":`)
+		buf.Write(quoteJSON(e.value))
+		buf.WriteString(`,"count":`)
+		buf.Write(formatFloat(e.count, ShortestPrecision))
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// topKEntry is a tracked value of SpaceSaving
+type topKEntry struct {
+	value string
+	count float64
+	// err is maximum overestimation of count
+	err   float64
+	index int
+}
+
+// topKHeap is implemented heap.Interface, the minimum count is the root
+type topKHeap []*topKEntry
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *topKHeap) Push(x interface{}) {
+	e := x.(*topKEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *topKHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// TopK count a value for TopKMetrics, tracked DefaultTopK values when not registered
+func (c *SimpleCollector) TopK(key string, value string) {
+	if key, err := c.topK(key, value); err == nil {
+		c.notify(Event{Key: key, Type: TypeTopK, Values: []string{value}})
+	}
+}
+
+func (c *SimpleCollector) topK(key string, value string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return "", ErrInvalidKey
+	}
+
+	// add key
+	if _, dup := c.metrics[key]; !dup {
+		c.metrics[key] = &TopKMetrics{
+			key:   key,
+			value: NewSpaceSaving(DefaultTopK),
+		}
+	}
+
+	// add value, ignore otherwise
+	v, ok := c.metrics[key].(*TopKMetrics)
+	if !ok {
+		return "", ErrMismatchMetricType
+	}
+	v.value.Add(value)
+	return key, nil
+}
+
+// RegisterTopK add TopKMetrics tracked k values, return ErrInvalidTopK when k is not positive
+func (c *SimpleCollector) RegisterTopK(key string, k int) error {
+	if k <= 0 {
+		return errors.Wrapf(ErrInvalidTopK, "k %d", k)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.normalizeKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+
+	if _, dup := c.metrics[key]; dup {
+		return ErrAlreadyExistMetrics
+	}
+	c.metrics[key] = &TopKMetrics{
+		key:   key,
+		value: NewSpaceSaving(k),
+	}
+	return nil
+}
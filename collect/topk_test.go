@@ -0,0 +1,113 @@
+package collect
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTopK(t *testing.T) {
+	c := NewSimpleCollector()
+	if err := c.RegisterTopK("tk", 2); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.RegisterTopK("tk", 2); err != ErrAlreadyExistMetrics {
+		t.Fatalf("want error %v, got %v", ErrAlreadyExistMetrics, err)
+	}
+	for _, v := range []string{"a", "b", "a", "c", "a", "b"} {
+		c.TopK("tk", v)
+	}
+	if got := c.metrics["tk"].GetType(); got != TypeTopK {
+		t.Fatalf("want type %s, got %s", TypeTopK, got)
+	}
+
+	// "c" replaced "b" and inherited its count, then "b" replaced "c"
+	expect := []byte(`{"tk":[{"value":"a","count":3},{"value":"b","count":3}]}`)
+	got, err := c.GetMetrics("tk")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestSpaceSavingHeavyHitters(t *testing.T) {
+	// values more frequent than 1000/10 are guaranteed to be tracked
+	s := NewSpaceSaving(10)
+	for i := 0; i < 1000; i++ {
+		switch {
+		case i%3 == 0:
+			s.Add("hot")
+		case i%5 == 0:
+			s.Add("warm")
+		default:
+			s.Add(fmt.Sprintf("noise-%d", i))
+		}
+	}
+
+	list := s.ranking().v
+	if len(list) != 10 {
+		t.Fatalf("want size %d, got %d", 10, len(list))
+	}
+	tracked := make(map[string]bool)
+	for _, e := range list {
+		tracked[e.value] = true
+	}
+	if list[0].value != "hot" || !tracked["warm"] {
+		t.Errorf("want tracked hot and warm, got %v", list)
+	}
+	for _, e := range list {
+		// expect: true count is within the error bound
+		if e.value == "hot" && (e.count < 334 || e.count-e.err > 334) {
+			t.Errorf("want estimated count of hot around 334, got %v", e)
+		}
+	}
+}
+
+func TestSpaceSavingMerge(t *testing.T) {
+	s1 := NewSpaceSaving(3)
+	s2 := NewSpaceSaving(3)
+	for i := 0; i < 5; i++ {
+		s1.Add("a")
+		s2.Add("a")
+	}
+	s1.Add("b")
+	s2.Add("c")
+	s2.Add("c")
+
+	s1.merge(s2)
+	expect := []topKEntry{
+		{value: "a", count: 10},
+		{value: "c", count: 2},
+		{value: "b", count: 1},
+	}
+	got := s1.ranking().v
+	for i := range got {
+		got[i].index = 0
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+}
+
+func TestRegisterTopKInvalid(t *testing.T) {
+	c := NewSimpleCollector()
+	for i, k := range []int{0, -1} {
+		if err := c.RegisterTopK("tk", k); errors.Cause(err) != ErrInvalidTopK {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidTopK, err)
+		}
+	}
+	if keys := c.GetMetricsKeys(); len(keys) != 0 {
+		t.Errorf("want no keys, got %v", keys)
+	}
+
+	// not panic, and track at least 1 value
+	s := NewSpaceSaving(-1)
+	s.Add("a")
+	if got := len(s.ranking().v); got != 1 {
+		t.Errorf("want 1 value, got %d", got)
+	}
+}
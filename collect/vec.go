@@ -0,0 +1,228 @@
+package collect
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// about label errors
+var (
+	ErrInvalidLabel = errors.New("invalid label")
+)
+
+// labelValuesSeparator joins label values to identify cached children, it is not valid UTF-8
+const labelValuesSeparator = "\xff"
+
+// metricVec is a set of children bound to the name and label values
+type metricVec struct {
+	c          *SimpleCollector
+	name       string
+	labelNames []string
+	children   map[string]vecChild
+	mu         sync.RWMutex
+}
+
+// vecChild is a cached handle and the metrics bound to it
+type vecChild struct {
+	key    string
+	handle interface{}
+	m      Metrics
+}
+
+// newMetricVec return metricVec with valid label names,
+// return ErrInvalidKey when the name is rejected by KeyPolicy
+func newMetricVec(c *SimpleCollector, name string, labelNames []string) (*metricVec, error) {
+	c.mu.Lock()
+	name, ok := c.normalizeKey(name)
+	c.mu.Unlock()
+	if !ok || name == "" {
+		return nil, ErrInvalidKey
+	}
+	if len(labelNames) == 0 {
+		return nil, errors.Wrap(ErrInvalidLabel, "no label names")
+	}
+	seen := make(map[string]struct{}, len(labelNames))
+	for _, l := range labelNames {
+		if !validLabelName(l) {
+			return nil, errors.Wrapf(ErrInvalidLabel, "label name %q", l)
+		}
+		if _, dup := seen[l]; dup {
+			return nil, errors.Wrapf(ErrInvalidLabel, "duplicate label name %q", l)
+		}
+		seen[l] = struct{}{}
+	}
+	names := make([]string, len(labelNames))
+	copy(names, labelNames)
+	return &metricVec{
+		c:          c,
+		name:       name,
+		labelNames: names,
+		children:   make(map[string]vecChild),
+	}, nil
+}
+
+// validLabelName return whether the name is [a-zA-Z_][a-zA-Z0-9_]* and not reserved by "__"
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, r := range name {
+		if !(isASCIILetter(r) || r == '_' || (i > 0 && isASCIIDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// child return the cached child of label values, or create it by the labeled key.
+// the child is created again when the bound metrics is deleted or replaced, e.g. Delete and LoadState
+func (v *metricVec) child(values []string, create func(key string) (interface{}, Metrics)) (interface{}, error) {
+	if len(values) != len(v.labelNames) {
+		return nil, errors.Wrapf(ErrInvalidLabel, "want %d label values, got %d", len(v.labelNames), len(values))
+	}
+	for _, s := range values {
+		if !utf8.ValidString(s) {
+			return nil, errors.Wrapf(ErrInvalidLabel, "label value %q", s)
+		}
+	}
+
+	id := strings.Join(values, labelValuesSeparator)
+	v.mu.RLock()
+	ch, ok := v.children[id]
+	v.mu.RUnlock()
+	if ok && v.bound(ch) {
+		return ch.handle, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if ch, ok := v.children[id]; ok && v.bound(ch) {
+		return ch.handle, nil
+	}
+	labels := make(map[string]string, len(values))
+	for i, s := range values {
+		labels[v.labelNames[i]] = s
+	}
+	key := LabeledKey(v.name, labels)
+	h, m := create(key)
+	v.children[id] = vecChild{key: key, handle: h, m: m}
+	return h, nil
+}
+
+// bound return whether the child is still bound to the metrics of the collector
+func (v *metricVec) bound(ch vecChild) bool {
+	v.c.mu.RLock()
+	defer v.c.mu.RUnlock()
+	return v.c.metrics[ch.key] == ch.m
+}
+
+// CounterVec is a set of Counter handles partitioned by label values
+type CounterVec struct {
+	vec *metricVec
+}
+
+// NewCounterVec return CounterVec with fixed label names
+func (c *SimpleCollector) NewCounterVec(name string, labelNames ...string) (*CounterVec, error) {
+	v, err := newMetricVec(c, name, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterVec{vec: v}, nil
+}
+
+// GetWith return the Counter of label values in order of label names,
+// return ErrInvalidLabel when the label values are invalid
+func (v *CounterVec) GetWith(values ...string) (*Counter, error) {
+	h, err := v.vec.child(values, func(key string) (interface{}, Metrics) {
+		h := v.vec.c.Counter(key)
+		return h, h.m
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.(*Counter), nil
+}
+
+// With return the Counter of label values, the Counter does nothing when label values are invalid
+func (v *CounterVec) With(values ...string) *Counter {
+	h, err := v.GetWith(values...)
+	if err != nil {
+		return &Counter{}
+	}
+	return h
+}
+
+// GaugeVec is a set of Gauge handles partitioned by label values
+type GaugeVec struct {
+	vec *metricVec
+}
+
+// NewGaugeVec return GaugeVec with fixed label names
+func (c *SimpleCollector) NewGaugeVec(name string, labelNames ...string) (*GaugeVec, error) {
+	v, err := newMetricVec(c, name, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &GaugeVec{vec: v}, nil
+}
+
+// GetWith return the Gauge of label values in order of label names,
+// return ErrInvalidLabel when the label values are invalid
+func (v *GaugeVec) GetWith(values ...string) (*Gauge, error) {
+	h, err := v.vec.child(values, func(key string) (interface{}, Metrics) {
+		h := v.vec.c.NewGauge(key)
+		return h, h.m
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.(*Gauge), nil
+}
+
+// With return the Gauge of label values, the Gauge does nothing when label values are invalid
+func (v *GaugeVec) With(values ...string) *Gauge {
+	h, err := v.GetWith(values...)
+	if err != nil {
+		return &Gauge{}
+	}
+	return h
+}
+
+// HistogramVec is a set of Histogram handles partitioned by label values
+type HistogramVec struct {
+	vec *metricVec
+}
+
+// NewHistogramVec return HistogramVec with fixed label names
+func (c *SimpleCollector) NewHistogramVec(name string, labelNames ...string) (*HistogramVec, error) {
+	v, err := newMetricVec(c, name, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &HistogramVec{vec: v}, nil
+}
+
+// GetWith return the Histogram of label values in order of label names,
+// return ErrInvalidLabel when the label values are invalid
+func (v *HistogramVec) GetWith(values ...string) (*Histogram, error) {
+	h, err := v.vec.child(values, func(key string) (interface{}, Metrics) {
+		h := v.vec.c.NewHistogram(key)
+		return h, h.m
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.(*Histogram), nil
+}
+
+// With return the Histogram of label values, the Histogram does nothing when label values are invalid
+func (v *HistogramVec) With(values ...string) *Histogram {
+	h, err := v.GetWith(values...)
+	if err != nil {
+		return &Histogram{}
+	}
+	return h
+}
@@ -0,0 +1,155 @@
+package collect
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := NewSimpleCollector()
+	v, err := c.NewCounterVec("requests", "method", "code")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	v.With("GET", "200").Inc()
+	v.With("GET", "200").Add(2)
+	v.With("POST", "500").Inc()
+	if v.With("GET", "200") != v.With("GET", "200") {
+		t.Errorf("want cached child")
+	}
+
+	cases := []struct {
+		key    string
+		expect []byte
+	}{
+		{`requests{code="200",method="GET"}`, []byte(`{"requests{code=\"200\",method=\"GET\"}":3}`)},
+		{`requests{code="500",method="POST"}`, []byte(`{"requests{code=\"500\",method=\"POST\"}":1}`)},
+	}
+	for i, tc := range cases {
+		got, err := c.GetMetrics(tc.key)
+		if err != nil {
+			t.Fatalf("#%d: want no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("#%d: want %s, got %s", i, tc.expect, got)
+		}
+	}
+}
+
+func TestGaugeAndHistogramVec(t *testing.T) {
+	c := NewSimpleCollector()
+	gv, err := c.NewGaugeVec("queue", "name")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	hv, err := c.NewHistogramVec("latency", "route")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	gv.With("mail").Set(3)
+	hv.With("/").Observe(1)
+	hv.With("/").Observe(3)
+
+	expect := []string{`latency{route="/"}`, `queue{name="mail"}`}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+	got, err := c.GetMetrics(`latency{route="/"}`)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if expect := []byte(`{"latency{route=\"/\"}.95percentile":2.9,"latency{route=\"/\"}.avg":2,"latency{route=\"/\"}.count":2,"latency{route=\"/\"}.max":3,"latency{route=\"/\"}.median":3}`); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s", expect, got)
+	}
+}
+
+func TestVecInvalidLabels(t *testing.T) {
+	c := NewSimpleCollector()
+	for i, names := range [][]string{
+		{},
+		{""},
+		{"1code"},
+		{"__name"},
+		{"method-name"},
+		{"code", "code"},
+	} {
+		if _, err := c.NewCounterVec("requests", names...); errors.Cause(err) != ErrInvalidLabel {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidLabel, err)
+		}
+	}
+
+	v, err := c.NewCounterVec("requests", "method", "code")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	for i, values := range [][]string{
+		{"GET"},
+		{"GET", "200", "extra"},
+		{"GET", "\xff"},
+	} {
+		if _, err := v.GetWith(values...); errors.Cause(err) != ErrInvalidLabel {
+			t.Errorf("#%d: want error %v, got %v", i, ErrInvalidLabel, err)
+		}
+		// ignored
+		v.With(values...).Inc()
+	}
+	if got := c.GetMetricsKeys(); len(got) != 0 {
+		t.Errorf("want no keys, got %v", got)
+	}
+
+	// label values are escaped
+	v.With("GET", `"quoted"`).Inc()
+	expect := []string{`requests{code="\"quoted\"",method="GET"}`}
+	if got := c.GetMetricsKeys(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %v, got %v", expect, got)
+	}
+}
+
+func TestVecAfterDeleteAndLoadState(t *testing.T) {
+	c := NewSimpleCollector()
+	v, err := c.NewCounterVec("requests", "code")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	key := `requests{code="200"}`
+	v.With("200").Add(2)
+	if err := c.Delete(key); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	v.With("200").Add(1)
+	expect := []byte(`{"requests{code=\"200\"}":1}`)
+	if got, err := c.GetMetrics(key); err != nil || !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s, %v", expect, got, err)
+	}
+
+	// replaced by LoadState
+	var buf bytes.Buffer
+	if err := c.SaveState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := c.LoadState(&buf); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	v.With("200").Inc()
+	expect = []byte(`{"requests{code=\"200\"}":2}`)
+	if got, err := c.GetMetrics(key); err != nil || !reflect.DeepEqual(got, expect) {
+		t.Errorf("want %s, got %s, %v", expect, got, err)
+	}
+}
+
+func TestVecInvalidName(t *testing.T) {
+	c := NewSimpleCollector()
+	c.SetKeyPolicy(PrometheusKeyPolicy(KeyModeReject))
+	if _, err := c.NewCounterVec("bad name", "code"); err != ErrInvalidKey {
+		t.Errorf("want error %v, got %v", ErrInvalidKey, err)
+	}
+	if _, err := c.NewGaugeVec("", "code"); err != ErrInvalidKey {
+		t.Errorf("want error %v, got %v", ErrInvalidKey, err)
+	}
+	if _, err := c.NewHistogramVec("bad-name", "code"); err != ErrInvalidKey {
+		t.Errorf("want error %v, got %v", ErrInvalidKey, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"io"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -35,6 +36,8 @@ type SimpleWriter struct {
 	Destination io.Writer
 	MetricsKeys []string
 	Interval    time.Duration
+	// Clock is a source of ticks of RunStream, default is collect.SystemClock
+	Clock collect.Clock
 }
 
 // NewSimpleWriter return new SimpleWriter
@@ -46,6 +49,7 @@ func NewSimpleWriter(c collect.Collector, w io.Writer) (MetricsWriter, error) {
 	cw := &SimpleWriter{
 		MetricsKeys: make([]string, 0),
 		Interval:    time.Second,
+		Clock:       collect.SystemClock,
 	}
 	cw.SetSource(c)
 	cw.SetDestination(w)
@@ -80,7 +84,7 @@ func addMetrics(src []string, dst []string, adds []string) ([]string, error) {
 	}
 
 	for _, key := range adds {
-		if _, ok := existMap[key]; !ok {
+		if _, ok := existMap[key]; !ok && len(labeledKeys(src, key)) == 0 {
 			return nil, ErrNotExistMetrics
 		}
 	}
@@ -119,7 +123,7 @@ func (cw *SimpleWriter) FlushWithKeys(keys ...string) error {
 
 // flush write to Destination writer from collector
 func flush(c collect.Collector, w io.Writer, keys ...string) error {
-	buf, err := getMergedMetrics(c, keys...)
+	buf, err := getMergedMetrics(c, expandKeys(c.GetMetricsKeys(), keys)...)
 	if err != nil {
 		return err
 	}
@@ -127,6 +131,36 @@ func flush(c collect.Collector, w io.Writer, keys ...string) error {
 	return err
 }
 
+// expandKeys return keys followed by labeled keys of them, e.g. `requests{code="200"}` of "requests"
+func expandKeys(src []string, keys []string) []string {
+	res := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		for _, k := range append([]string{key}, labeledKeys(src, key)...) {
+			if _, dup := seen[k]; dup {
+				continue
+			}
+			seen[k] = struct{}{}
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// labeledKeys return keys of the name with labels made by collect.LabeledKey
+func labeledKeys(src []string, name string) []string {
+	res := make([]string, 0)
+	if strings.ContainsRune(name, '{') {
+		return res
+	}
+	for _, k := range src {
+		if strings.HasPrefix(k, name+"{") {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
 // getMergedMetrics return a merged metrics data
 func getMergedMetrics(c collect.Collector, keys ...string) (*bytes.Buffer, error) {
 	var (
@@ -149,8 +183,8 @@ func getMergedMetrics(c collect.Collector, keys ...string) (*bytes.Buffer, error
 		existKey = true
 
 		// trim "{}" and merge all metrics
-		b = bytes.TrimLeft(b, "{")
-		b = bytes.TrimRight(b, "}")
+		b = bytes.TrimPrefix(b, []byte("{"))
+		b = bytes.TrimSuffix(b, []byte("}"))
 		buf.Write(b)
 		if k != len(keys)-1 {
 			buf.WriteByte(',')
@@ -166,14 +200,17 @@ func getMergedMetrics(c collect.Collector, keys ...string) (*bytes.Buffer, error
 
 // RunStream run Flush() goroutine
 func (cw *SimpleWriter) RunStream(ctx context.Context) {
-	go runStream(ctx, cw, cw.Interval)
+	go runStream(ctx, cw, cw.Interval, cw.Clock)
 }
 
-func runStream(ctx context.Context, writer MetricsWriter, interval time.Duration) {
-	t := time.NewTicker(interval)
+func runStream(ctx context.Context, writer MetricsWriter, interval time.Duration, clock collect.Clock) {
+	if clock == nil {
+		clock = collect.SystemClock
+	}
+	t := clock.NewTicker(interval)
 	for {
 		select {
-		case <-t.C:
+		case <-t.Chan():
 			writer.Flush()
 		case <-ctx.Done():
 			t.Stop()
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"io"
 	"reflect"
-	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -136,7 +135,7 @@ func TestFlush(t *testing.T) {
 	if err := json.Unmarshal(buf.Bytes(), &dummy); err != nil {
 		t.Fatalf("want no error, got %v", err)
 	}
-	expect := []byte(`{"a":1.0,"b":1.0,"h.95percentile":0.0,"h.avg":1.0,"h.count":1.0,"h.max":1.0,"h.median":1.0,"s":["A","B"],"s2":["A'"]}`)
+	expect := []byte(`{"a":1,"b":1,"h.95percentile":0,"h.avg":1,"h.count":1,"h.max":1,"h.median":1,"s":["A","B"],"s2":["A'"]}`)
 	if !reflect.DeepEqual(buf.Bytes(), expect) {
 		t.Errorf("want %s, got %s", buf.Bytes(), expect)
 	}
@@ -167,34 +166,34 @@ func TestFlushWithKeys(t *testing.T) {
 	if err := json.Unmarshal(buf.Bytes(), &dummy); err != nil {
 		t.Fatalf("want no error, got %v", err)
 	}
-	expect := []byte(`{"a":1.0,"b":1.0,"s2":["A'"]}`)
+	expect := []byte(`{"a":1,"b":1,"s2":["A'"]}`)
 	if !reflect.DeepEqual(buf.Bytes(), expect) {
 		t.Errorf("want %s, got %s", buf.Bytes(), expect)
 	}
 }
 
 func TestStream(t *testing.T) {
-	expect := `{"a":1.0,"b":1.0,"c":1.0}`
+	expect := `{"a":1,"b":1,"c":1}`
 	cw := createDummySimpleWriterWithKeys(t, nil, []string{"a", "b", "c"}...)
 	cw.AddMetrics(cw.Source.GetMetricsKeys()...)
 
 	nw := noticeio.NewBufferWithChannel(nil, make(chan error, 1))
 	cw.SetDestination(nw)
-	cw.Interval = 10 * time.Millisecond
+	clock := collect.NewFakeClock(time.Unix(1500000000, 0))
+	cw.Clock = clock
 
-	// testing cancel
 	ctx, cancel := context.WithCancel(context.Background())
 	cw.RunStream(ctx)
-	before := runtime.NumGoroutine()
+	clock.WaitTickers(1)
 	for i := 0; i < 2; i++ {
 		// waiting write twice
+		clock.Advance(cw.Interval)
 		<-nw.WriteCh
 	}
+
+	// testing cancel, the ticker is stopped when the goroutine returns
 	cancel()
-	time.Sleep(20 * time.Millisecond)
-	if after := runtime.NumGoroutine(); before-1 != after {
-		t.Errorf("want num %d, got %d", before-1, after)
-	}
+	clock.WaitTickers(0)
 
 	// testing writer
 	bs := make([]byte, 1024)
@@ -207,3 +206,51 @@ func TestStream(t *testing.T) {
 		t.Errorf("want has prefix %s, got %s", expect, message[:len(expect)])
 	}
 }
+
+func TestFlushWithExemplar(t *testing.T) {
+	sc := collect.NewSimpleCollector()
+	sc.AddWithExemplar("a", 1, collect.Exemplar{TraceID: "abc", Timestamp: time.Unix(1500000000, 0)})
+	sc.Add("b", 1)
+	var buf bytes.Buffer
+	w, err := NewSimpleWriter(sc, &buf)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := w.FlushWithKeys("a", "b"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := []byte(`{"a":1,"a.exemplar":{"labels":{"trace_id":"abc"},"timestamp":1500000000,"value":1},"b":1}`)
+	if !reflect.DeepEqual(buf.Bytes(), expect) {
+		t.Errorf("want %s, got %s", expect, buf.Bytes())
+	}
+}
+
+func TestFlushWithLabeledKeys(t *testing.T) {
+	sc := collect.NewSimpleCollector()
+	v, err := sc.NewCounterVec("requests", "method", "code")
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	v.With("GET", "200").Inc()
+	v.With("POST", "500").Inc()
+	sc.Add("requests_total", 2)
+
+	var buf bytes.Buffer
+	mw, err := NewSimpleWriter(sc, &buf)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	// the name without labels is registered
+	if err := mw.AddMetrics("requests", "requests_total"); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	// children added after registered are also flushed
+	v.With("GET", "404").Inc()
+	if err := mw.Flush(); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	expect := []byte(`{"requests{code=\"200\",method=\"GET\"}":1,"requests{code=\"404\",method=\"GET\"}":1,"requests{code=\"500\",method=\"POST\"}":1,"requests_total":2}`)
+	if !reflect.DeepEqual(buf.Bytes(), expect) {
+		t.Errorf("want %s, got %s", expect, buf.Bytes())
+	}
+}
@@ -15,6 +15,8 @@ type NetWriter struct {
 	Destination io.Writer
 	MetricsKeys []string
 	Interval    time.Duration
+	// Clock is a source of ticks of RunStream, default is collect.SystemClock
+	Clock collect.Clock
 }
 
 // NewNetWriter return new NetWriter
@@ -31,6 +33,7 @@ func NewNetWriter(c collect.Collector, addr string) (MetricsWriter, error) {
 	w := &NetWriter{
 		MetricsKeys: make([]string, 0),
 		Interval:    time.Second,
+		Clock:       collect.SystemClock,
 	}
 	w.SetSource(c)
 	w.SetDestination(conn)
@@ -76,5 +79,5 @@ func (cw *NetWriter) FlushWithKeys(keys ...string) error {
 
 // RunStream run Flush() goroutine
 func (cw *NetWriter) RunStream(ctx context.Context) {
-	go runStream(ctx, cw, cw.Interval)
+	go runStream(ctx, cw, cw.Interval, cw.Clock)
 }
@@ -46,7 +46,7 @@ func TestNetWriter(t *testing.T) {
 	if err := client.Flush(); err != nil {
 		t.Fatalf("want no error, got %v", err)
 	}
-	expect := `{"a":1.0,"b":1.0,"c":1.0}`
+	expect := `{"a":1,"b":1,"c":1}`
 
 	bs := make([]byte, 1024)
 	n, _, err := ts.ReadFrom(bs)
@@ -63,17 +63,19 @@ func TestNetStream(t *testing.T) {
 	ts := createTestUDPServer(t)
 	defer ts.Close()
 
-	expect := `{"a":1.0,"b":1.0,"c":1.0}`
+	expect := `{"a":1,"b":1,"c":1}`
 	client := createDummyNetWriterWithKeys(t, ts.LocalAddr().String(), []string{"a", "b", "c"}...)
 	if err := client.AddMetrics(client.Source.GetMetricsKeys()...); err != nil {
 		t.Fatalf("want no error, got %v", err)
 	}
-	client.Interval = 10 * time.Millisecond
+	clock := collect.NewFakeClock(time.Unix(1500000000, 0))
+	client.Clock = clock
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	client.RunStream(ctx)
-	time.Sleep(20 * time.Millisecond) // wait waiting at goroutine
-	cancel()
+	clock.WaitTickers(1)
+	clock.Advance(client.Interval)
 
 	bs := make([]byte, 1024)
 	n, _, err := ts.ReadFrom(bs)